@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// BatchItem is a single entry written by SetMany
+type BatchItem struct {
+	Key   string
+	Value any
+	// Expiration of the entry, 0 means the entry does not expire
+	Expiration time.Duration
+}
+
+// BatchResult is the outcome of a batch operation for a single key
+type BatchResult struct {
+	Key string
+	// CacheModel is the entry returned by GetMany, nil if it does not exist
+	CacheModel *comby.CacheModel
+	// Err is set if the operation failed for this key only
+	Err error
+}
+
+func (csr *cacheStoreRedis) GetMany(ctx context.Context, keys ...string) ([]*BatchResult, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = csr.key(key)
+	}
+
+	// fetch values and remaining time to live within a single round trip,
+	// Redis Cluster rejects MGET of keys in different hash slots
+	pipe := csr.readClient.Pipeline()
+	var mgetCmd *redis.SliceCmd
+	getCmds := make([]*redis.StringCmd, len(keys))
+	if csr.clusterOptions == nil {
+		mgetCmd = pipe.MGet(ctx, redisKeys...)
+	} else {
+		for i, redisKey := range redisKeys {
+			getCmds[i] = pipe.Get(ctx, redisKey)
+		}
+	}
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	for i, redisKey := range redisKeys {
+		ttlCmds[i] = pipe.PTTL(ctx, redisKey)
+	}
+	now := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	results := make([]*BatchResult, len(keys))
+	for i, key := range keys {
+		results[i] = &BatchResult{Key: key}
+
+		var value string
+		if mgetCmd != nil {
+			strValue, ok := mgetCmd.Val()[i].(string)
+			if !ok { // key does not exist
+				continue
+			}
+			value = strValue
+		} else {
+			strValue, err := getCmds[i].Result()
+			switch {
+			case err == redis.Nil: // key does not exist
+				continue
+			case err != nil: // failed to get
+				results[i].Err = err
+				continue
+			}
+			value = strValue
+		}
+
+		// value is stored as string in Redis, convert to []byte for decoding
+		valueToReturn, err := csr.decodeValue(key, []byte(value))
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].CacheModel = &comby.CacheModel{
+			Key:       key,
+			Value:     valueToReturn,
+			ExpiredAt: expiredAt(now, ttlCmds[i].Val()),
+		}
+	}
+	return results, nil
+}
+
+func (csr *cacheStoreRedis) SetMany(ctx context.Context, items ...*BatchItem) ([]*BatchResult, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	results := make([]*BatchResult, len(items))
+	cmds := make([]redis.Cmder, len(items))
+
+	// tags of previous writes no longer apply
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	if err := csr.untag(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	// write all values within a single round trip
+	pipe := csr.redisClient.Pipeline()
+	for i, item := range items {
+		results[i] = &BatchResult{Key: item.Key}
+		valueToStore, err := csr.encodeEntry(item.Key, item.Value, item.Expiration, csr.storeOptions.SoftTTL)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		cmds[i] = csr.queueSet(ctx, pipe, item.Key, valueToStore, item.Expiration)
+	}
+	if pipe.Len() == 0 {
+		return results, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !hasCmdErrors(cmds) {
+		return nil, err
+	}
+	written := make([]string, 0, len(items))
+	for i, cmd := range cmds {
+		switch {
+		case cmd == nil:
+		case cmd.Err() != nil:
+			results[i].Err = csr.quotaError(items[i].Key, cmd.Err())
+		default:
+			written = append(written, items[i].Key)
+			written = append(written, csr.evictedKeys(cmd)...)
+		}
+	}
+	csr.invalidate(ctx, written...)
+	return results, nil
+}
+
+func (csr *cacheStoreRedis) DeleteMany(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = csr.key(key)
+	}
+	// Redis Cluster rejects UNLINK of keys in different hash slots
+	pipe := csr.redisClient.Pipeline()
+	var cmds []*redis.IntCmd
+	if csr.clusterOptions == nil {
+		cmds = append(cmds, pipe.Unlink(ctx, redisKeys...))
+	} else {
+		for _, redisKey := range redisKeys {
+			cmds = append(cmds, pipe.Unlink(ctx, redisKey))
+		}
+	}
+	csr.unindexTenant(ctx, pipe, keys...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	if err := csr.untag(ctx, keys...); err != nil {
+		return 0, err
+	}
+	csr.invalidate(ctx, keys...)
+	var removed int64
+	for _, cmd := range cmds {
+		removed += cmd.Val()
+	}
+	return removed, nil
+}
+
+// hasCmdErrors reports whether an error returned by Exec originates from
+// individual commands rather than the connection
+func hasCmdErrors(cmds []redis.Cmder) bool {
+	for _, cmd := range cmds {
+		var redisErr redis.Error
+		if cmd != nil && errors.As(cmd.Err(), &redisErr) {
+			return true
+		}
+	}
+	return false
+}
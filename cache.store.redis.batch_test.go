@@ -0,0 +1,109 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_Batch(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// create crypto service with 32-byte key for AES-256
+	key := []byte("01234567890123456789012345678901")
+	cryptoService, err := comby.NewCryptoService(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setup and init store with crypto service
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 1,
+		comby.CacheStoreOptionWithCryptoService(cryptoService),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set many values, one of them can not be encoded
+	results, err := cacheStore.SetMany(ctx,
+		&store.BatchItem{Key: "batch1", Value: "value1", Expiration: time.Minute},
+		&store.BatchItem{Key: "batch2", Value: 2, Expiration: time.Hour},
+		&store.BatchItem{Key: "batch3", Value: make(chan int)},
+		&store.BatchItem{Key: "batch4", Value: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if i == 2 && result.Err == nil {
+			t.Fatalf("expected error for key %q", result.Key)
+		}
+		if i != 2 && result.Err != nil {
+			t.Fatalf("unexpected error for key %q: %v", result.Key, result.Err)
+		}
+	}
+
+	// write a value which can not be decrypted
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+	if err := redisClient.Set(ctx, "batch5", "not encrypted", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Get many values
+	results, err = cacheStore.GetMany(ctx, "batch1", "batch2", "batch3", "batch4", "batch5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].CacheModel == nil || results[0].CacheModel.Value != "value1" {
+		t.Fatalf("wrong result for batch1: %+v", results[0])
+	}
+	if results[1].CacheModel == nil || results[1].CacheModel.Value != 2 {
+		t.Fatalf("wrong result for batch2: %+v", results[1])
+	}
+	if results[0].CacheModel.ExpiredAt >= results[1].CacheModel.ExpiredAt {
+		t.Fatalf("expected per item expiration")
+	}
+	if results[2].CacheModel != nil || results[2].Err != nil {
+		t.Fatalf("expected missing batch3: %+v", results[2])
+	}
+	if results[3].CacheModel == nil || results[3].CacheModel.Value != true || results[3].CacheModel.ExpiredAt != 0 {
+		t.Fatalf("wrong result for batch4: %+v", results[3])
+	}
+	if results[4].Err == nil {
+		t.Fatalf("expected decrypt error for batch5")
+	}
+
+	// Delete many values
+	if removed, err := cacheStore.DeleteMany(ctx, "batch1", "batch2", "batch3"); err != nil {
+		t.Fatal(err)
+	} else if removed != 2 {
+		t.Fatalf("expected 2 removed keys, got %d", removed)
+	}
+	if cacheStore.Total(ctx) != 2 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen is returned instead of calling Redis while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed passes all calls to Redis
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen passes a single probe, closing the circuit if it
+	// succeeds and opening it again otherwise
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// CircuitBreakerOptions configure the circuit breaker
+type CircuitBreakerOptions struct {
+	// FailureRate opens the circuit once this fraction of the calls within
+	// a window failed
+	FailureRate float64
+	// MinRequests is the number of calls within a window required before
+	// the failure rate is evaluated
+	MinRequests int
+	// Window is the duration calls are counted for
+	Window time.Duration
+	// OpenTimeout is how long the circuit stays open before probing
+	OpenTimeout time.Duration
+}
+
+// circuitBreaker is a go-redis hook rejecting calls while Redis is
+// considered unavailable
+type circuitBreaker struct {
+	opts *CircuitBreakerOptions
+	// onClose is called when the circuit closes after being open
+	onClose func()
+
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// Make sure it implements interfaces
+var _ redis.Hook = (*circuitBreaker)(nil)
+
+func newCircuitBreaker(opts *CircuitBreakerOptions, onClose func()) *circuitBreaker {
+	return &circuitBreaker{opts: opts, onClose: onClose, windowStart: time.Now()}
+}
+
+func (cb *circuitBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (cb *circuitBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		probe, err := cb.allow()
+		if err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err = next(ctx, cmd)
+		cb.record(ctx, probe, err)
+		return err
+	}
+}
+
+func (cb *circuitBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		probe, err := cb.allow()
+		if err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err = next(ctx, cmds)
+		cb.record(ctx, probe, err)
+		return err
+	}
+}
+
+func (cb *circuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.opts.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow reports whether a call may pass and whether it is the probe of the
+// half-open circuit
+func (cb *circuitBreaker) allow() (bool, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.opts.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+		cb.state = CircuitHalfOpen
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.probing {
+			return false, ErrCircuitOpen
+		}
+		cb.probing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record counts the outcome of a call and transitions the state. Calls
+// canceled by the caller are not counted, while calls exceeding the deadline
+// of the caller are, as a hung Redis only shows by timeouts.
+func (cb *circuitBreaker) record(ctx context.Context, probe bool, err error) {
+	failed := isUnavailable(err)
+
+	cb.mu.Lock()
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		if probe {
+			cb.probing = false
+		}
+		cb.mu.Unlock()
+		return
+	}
+	if probe {
+		cb.probing = false
+		if failed {
+			cb.open()
+			cb.mu.Unlock()
+			return
+		}
+		cb.state = CircuitClosed
+		cb.resetWindow()
+		cb.mu.Unlock()
+		if cb.onClose != nil {
+			cb.onClose()
+		}
+		return
+	}
+	defer cb.mu.Unlock()
+	if cb.state != CircuitClosed {
+		return
+	}
+	if time.Since(cb.windowStart) >= cb.opts.Window {
+		cb.resetWindow()
+	}
+	cb.requests++
+	if failed {
+		cb.failures++
+	}
+	if cb.requests >= cb.opts.MinRequests && float64(cb.failures) >= cb.opts.FailureRate*float64(cb.requests) {
+		cb.open()
+	}
+}
+
+func (cb *circuitBreaker) open() {
+	cb.state = CircuitOpen
+	cb.openedAt = time.Now()
+}
+
+func (cb *circuitBreaker) resetWindow() {
+	cb.windowStart = time.Now()
+	cb.requests, cb.failures = 0, 0
+}
+
+// isUnavailable reports whether err indicates Redis being unreachable, as
+// opposed to replies like redis.Nil, errors returned by the server or the
+// caller canceling the call. Timeouts count as Redis being unreachable.
+func isUnavailable(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, redis.ErrClosed) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
+
+func (csr *cacheStoreRedis) CircuitState() CircuitState {
+	if csr.breaker == nil {
+		return CircuitClosed
+	}
+	return csr.breaker.State()
+}
+
+// useFallback reports whether the call failed due to the open circuit and
+// should be served by the fallback store
+func (csr *cacheStoreRedis) useFallback(err error) bool {
+	return csr.storeOptions.Fallback != nil && errors.Is(err, ErrCircuitOpen)
+}
+
+// markFallbackWrite remembers keys written to the fallback store, they are
+// removed from Redis once the circuit closes as Redis missed the write
+func (csr *cacheStoreRedis) markFallbackWrite(keys ...string) {
+	csr.fallbackMu.Lock()
+	defer csr.fallbackMu.Unlock()
+	if csr.fallbackKeys == nil {
+		csr.fallbackKeys = make(map[string]struct{})
+	}
+	for _, key := range keys {
+		csr.fallbackKeys[key] = struct{}{}
+	}
+	csr.fallbackUsed = true
+	if csr.nearCache != nil {
+		csr.nearCache.invalidate(keys...)
+	}
+}
+
+// markFallbackReset remembers a Reset served by the fallback store, Redis
+// is reset once the circuit closes
+func (csr *cacheStoreRedis) markFallbackReset() {
+	csr.fallbackMu.Lock()
+	defer csr.fallbackMu.Unlock()
+	csr.fallbackKeys = nil
+	csr.fallbackReset = true
+	csr.fallbackUsed = true
+	if csr.nearCache != nil {
+		csr.nearCache.flush()
+	}
+}
+
+// reconcile applies the writes served by the fallback store to Redis after
+// the circuit closed and resets the fallback store, whose entries are not
+// kept in sync with Redis
+func (csr *cacheStoreRedis) reconcile() {
+	csr.fallbackMu.Lock()
+	if !csr.fallbackUsed {
+		csr.fallbackMu.Unlock()
+		return
+	}
+	keys := make([]string, 0, len(csr.fallbackKeys))
+	for key := range csr.fallbackKeys {
+		keys = append(keys, key)
+	}
+	reset := csr.fallbackReset
+	csr.fallbackKeys, csr.fallbackReset, csr.fallbackUsed = nil, false, false
+	csr.fallbackMu.Unlock()
+
+	csr.fallbackWG.Add(1)
+	go func() {
+		defer csr.fallbackWG.Done()
+		ctx := context.Background()
+		var err error
+		if reset {
+			err = csr.reset(ctx)
+		} else if len(keys) > 0 {
+			_, err = csr.DeleteMany(ctx, keys...)
+		}
+		if err != nil {
+			// try again when the circuit closes next time
+			if reset {
+				csr.markFallbackReset()
+			} else {
+				csr.markFallbackWrite(keys...)
+			}
+			return
+		}
+		csr.storeOptions.Fallback.Reset(ctx)
+	}()
+}
@@ -0,0 +1,276 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// flakyConn fails reads and writes while down is set
+type flakyConn struct {
+	net.Conn
+	down *atomic.Bool
+}
+
+func (c *flakyConn) Read(b []byte) (int, error) {
+	if c.down.Load() {
+		return 0, errors.New("connection down")
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *flakyConn) Write(b []byte) (int, error) {
+	if c.down.Load() {
+		return 0, errors.New("connection down")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestCacheStore_CircuitBreaker(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with a connection which can be taken down
+	var down atomic.Bool
+	redisOptions := &redis.Options{
+		Addr:       "localhost:6379",
+		DB:         1,
+		MaxRetries: -1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if down.Load() {
+				return nil, errors.New("connection down")
+			}
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &flakyConn{Conn: conn, down: &down}, nil
+		},
+	}
+	fallback := comby.NewCacheStoreMemory()
+	if err = fallback.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cacheStore := store.NewCacheStoreRedisWithOptions(redisOptions,
+		store.OptionWithCircuitBreaker(0.5, 2, time.Minute, 200*time.Millisecond),
+		store.OptionWithFallback(fallback),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	getValue := func() any {
+		cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cacheModel == nil {
+			return nil
+		}
+		return cacheModel.Value
+	}
+	if err := cacheStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// failing calls open the circuit
+	down.Store(true)
+	for i := 0; i < 2; i++ {
+		if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("key")); err == nil {
+			t.Fatalf("expected error while Redis is down")
+		}
+	}
+	if state := cacheStore.CircuitState(); state != store.CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	// the fallback store serves calls while the circuit is open
+	if err := cacheStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "v2")); err != nil {
+		t.Fatal(err)
+	}
+	if value := getValue(); value != "v2" {
+		t.Fatalf("expected value of fallback store, got %v", value)
+	}
+	if _, err := cacheStore.GetMany(ctx, "key"); !errors.Is(err, store.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// a successful probe closes the circuit, keys written to the fallback
+	// store are removed from Redis
+	down.Store(false)
+	time.Sleep(200 * time.Millisecond)
+	if state := cacheStore.CircuitState(); state != store.CircuitHalfOpen {
+		t.Fatalf("expected half-open circuit, got %s", state)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for getValue() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected key written to fallback store to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := cacheStore.CircuitState(); state != store.CircuitClosed {
+		t.Fatalf("expected closed circuit, got %s", state)
+	}
+
+	// close connection, waiting for the reconciliation
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+	if total := fallback.Total(ctx); total != 0 {
+		t.Fatalf("expected fallback store to be reset, got %d entries", total)
+	}
+}
+
+func TestCacheStore_CircuitBreakerIgnoresServerErrors(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithCircuitBreaker(0.5, 1, time.Minute, time.Minute),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// misses are no failures
+	for i := 0; i < 3; i++ {
+		if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("missing")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if state := cacheStore.CircuitState(); state != store.CircuitClosed {
+		t.Fatalf("expected closed circuit, got %s", state)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_CircuitBreakerWithoutFallback(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with a connection which can be taken down
+	var down atomic.Bool
+	redisOptions := &redis.Options{
+		Addr:       "localhost:6379",
+		DB:         1,
+		MaxRetries: -1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if down.Load() {
+				return nil, errors.New("connection down")
+			}
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &flakyConn{Conn: conn, down: &down}, nil
+		},
+	}
+	cacheStore := store.NewCacheStoreRedisWithOptions(redisOptions,
+		store.OptionWithCircuitBreaker(0.5, 2, time.Minute, time.Minute),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// calls canceled by the caller are no failures
+	for i := 0; i < 3; i++ {
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := cacheStore.Get(canceledCtx, comby.CacheStoreGetOptionWithKey("key")); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+	if state := cacheStore.CircuitState(); state != store.CircuitClosed {
+		t.Fatalf("expected closed circuit, got %s", state)
+	}
+
+	// failing calls open the circuit
+	down.Store(true)
+	for i := 0; i < 2; i++ {
+		if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("key")); err == nil {
+			t.Fatalf("expected error while Redis is down")
+		}
+	}
+	if state := cacheStore.CircuitState(); state != store.CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	// calls are rejected without fallback store
+	if err := cacheStore.Delete(ctx, comby.CacheStoreDeleteOptionWithKey("key")); !errors.Is(err, store.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	down.Store(false)
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_CircuitBreakerHungServer(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// server accepting connections but never replying
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// setup and init store honouring the deadline of the caller
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{
+		Addr:                  listener.Addr().String(),
+		MaxRetries:            -1,
+		ContextTimeoutEnabled: true,
+	},
+		store.OptionWithCircuitBreaker(0.5, 5, time.Minute, time.Minute),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// calls exceeding the deadline of the caller open the circuit
+	for i := 0; i < 5; i++ {
+		timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		if _, err := cacheStore.Get(timeoutCtx, comby.CacheStoreGetOptionWithKey("key")); !errors.Is(err, store.ErrTimeout) {
+			t.Fatalf("expected ErrTimeout, got %v", err)
+		}
+		cancel()
+	}
+	if state := cacheStore.CircuitState(); state != store.CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", state)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
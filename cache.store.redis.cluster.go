@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewCacheStoreRedisCluster creates a cache store backed by Redis Cluster.
+// Operations on single keys are routed to the owning master, while List,
+// Total, Reset and Info are fanned out over all masters and merged.
+func NewCacheStoreRedisCluster(
+	clusterOptions *redis.ClusterOptions,
+	opts ...Option,
+) CacheStoreRedis {
+	csr, err := newCacheStoreRedis(opts...)
+	if err != nil {
+		return nil
+	}
+	csr.clusterOptions = clusterOptions
+	return csr
+}
+
+// forEachShard calls fn for every master of Redis Cluster concurrently or
+// once for the single node otherwise.
+func (csr *cacheStoreRedis) forEachShard(ctx context.Context, client redis.UniversalClient, fn func(ctx context.Context, client *redis.Client) error) error {
+	switch client := client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, fn)
+	case *redis.Client:
+		return fn(ctx, client)
+	}
+	return fmt.Errorf("'%s' failed - unsupported redis client %T", csr.String(), client)
+}
@@ -0,0 +1,144 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// clusterAddrs returns the addresses of a running Redis Cluster, e.g.
+// REDIS_CLUSTER_ADDRS=localhost:7000,localhost:7001,localhost:7002
+func clusterAddrs(t *testing.T) []string {
+	addrs := os.Getenv("REDIS_CLUSTER_ADDRS")
+	if len(addrs) == 0 {
+		t.Skip("REDIS_CLUSTER_ADDRS not set")
+	}
+	return strings.Split(addrs, ",")
+}
+
+func TestCacheStoreCluster(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisCluster(&redis.ClusterOptions{
+		Addrs: clusterAddrs(t),
+	})
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// check totals
+	if cacheStore.Total(ctx) != 0 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// Set values spread over all hash slots
+	for i := 0; i < 100; i++ {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("tenant1-key%d", i), i),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Get a value
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("tenant1-key42"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != 42 {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+
+	// List merges the keys of all masters
+	if cacheModels, total, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("tenant1"),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		if len(cacheModels) != 100 {
+			t.Fatalf("expected 100 keys, got %d", len(cacheModels))
+		}
+		if total != 100 {
+			t.Fatalf("expected total 100, got %d", total)
+		}
+	}
+
+	// Total and Info sum up all masters
+	if cacheStore.Total(ctx) != 100 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+	if info, err := cacheStore.Info(ctx); err != nil {
+		t.Fatal(err)
+	} else if info.NumItems != 100 {
+		t.Fatalf("expected 100 items, got %d", info.NumItems)
+	}
+
+	// tags are not supported
+	if _, err := cacheStore.InvalidateTags(ctx, "tag"); err == nil {
+		t.Fatalf("expected error invalidating tags on cluster")
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheStore.Total(ctx) != 0 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStoreCluster_Namespace(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisCluster(&redis.ClusterOptions{
+		Addrs: clusterAddrs(t),
+	}, store.OptionWithNamespace("cache:"))
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set values spread over all hash slots
+	for i := 0; i < 50; i++ {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("key%d", i), i),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cacheStore.Total(ctx) != 50 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// reset namespace unlinks keys of different hash slots
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheStore.Total(ctx) != 0 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotEncoded is returned by Codec.Decode if the given data was not
+// produced by the codec, e.g. raw values written by previous versions.
+var ErrNotEncoded = errors.New("value not encoded by codec")
+
+// Codec serializes cache values before they are stored in Redis
+type Codec interface {
+	Encode(value any) ([]byte, error)
+	Decode(data []byte) (any, error)
+}
+
+// jsonEnvelope wraps a JSON encoded value together with its type tag
+type jsonEnvelope struct {
+	Type  string          `json:"_t"`
+	Value json.RawMessage `json:"_v"`
+}
+
+type jsonCodec struct{}
+
+// Make sure it implements interfaces
+var _ Codec = (*jsonCodec)(nil)
+
+// NewJSONCodec returns the default codec which stores values as JSON
+// envelope tagged with the value's type, so that Decode returns the same
+// type family Encode was given. Structs, maps and slices are returned as
+// their generic JSON representation (map[string]any, []any).
+func NewJSONCodec() Codec {
+	return &jsonCodec{}
+}
+
+func (c *jsonCodec) Encode(value any) ([]byte, error) {
+	var typ string
+	switch value.(type) {
+	case nil:
+		typ = "nil"
+	case string:
+		typ = "string"
+	case bool:
+		typ = "bool"
+	case int:
+		typ = "int"
+	case int8:
+		typ = "int8"
+	case int16:
+		typ = "int16"
+	case int32:
+		typ = "int32"
+	case int64:
+		typ = "int64"
+	case uint:
+		typ = "uint"
+	case uint8:
+		typ = "uint8"
+	case uint16:
+		typ = "uint16"
+	case uint32:
+		typ = "uint32"
+	case uint64:
+		typ = "uint64"
+	case float32:
+		typ = "float32"
+	case float64:
+		typ = "float64"
+	case []byte:
+		typ = "bytes"
+	case time.Time:
+		typ = "time"
+	default:
+		typ = "json"
+	}
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonEnvelope{
+		Type:  typ,
+		Value: rawValue,
+	})
+}
+
+func (c *jsonCodec) Decode(data []byte) (any, error) {
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil || len(envelope.Type) == 0 {
+		return nil, ErrNotEncoded
+	}
+	switch envelope.Type {
+	case "nil":
+		return nil, nil
+	case "string":
+		return decodeAs[string](envelope.Value)
+	case "bool":
+		return decodeAs[bool](envelope.Value)
+	case "int":
+		return decodeAs[int](envelope.Value)
+	case "int8":
+		return decodeAs[int8](envelope.Value)
+	case "int16":
+		return decodeAs[int16](envelope.Value)
+	case "int32":
+		return decodeAs[int32](envelope.Value)
+	case "int64":
+		return decodeAs[int64](envelope.Value)
+	case "uint":
+		return decodeAs[uint](envelope.Value)
+	case "uint8":
+		return decodeAs[uint8](envelope.Value)
+	case "uint16":
+		return decodeAs[uint16](envelope.Value)
+	case "uint32":
+		return decodeAs[uint32](envelope.Value)
+	case "uint64":
+		return decodeAs[uint64](envelope.Value)
+	case "float32":
+		return decodeAs[float32](envelope.Value)
+	case "float64":
+		return decodeAs[float64](envelope.Value)
+	case "bytes":
+		return decodeAs[[]byte](envelope.Value)
+	case "time":
+		return decodeAs[time.Time](envelope.Value)
+	case "json":
+		return decodeAs[any](envelope.Value)
+	}
+	return nil, fmt.Errorf("unknown value type %q", envelope.Type)
+}
+
+func decodeAs[T any](data []byte) (any, error) {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
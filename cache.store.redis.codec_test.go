@@ -0,0 +1,137 @@
+package store_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_CodecPreservesTypes(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 0)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	type readModel struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	values := map[string]any{
+		"string":  "value",
+		"bool":    true,
+		"int":     42,
+		"int64":   int64(1) << 60,
+		"uint8":   uint8(7),
+		"float64": 3.14,
+		"bytes":   []byte("raw bytes"),
+		"time":    now,
+		"nil":     nil,
+	}
+	for key, value := range values {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(key, value),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("struct", readModel{Name: "rm", Count: 3}),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// Get returns the same type that was stored
+	for key, value := range values {
+		cacheModel, err := cacheStore.Get(ctx,
+			comby.CacheStoreGetOptionWithKey(key),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cacheModel.Value, value) {
+			t.Fatalf("key %q: expected %v (%T), got %v (%T)", key, value, value, cacheModel.Value, cacheModel.Value)
+		}
+	}
+
+	// structs are returned as generic JSON representation
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("struct"),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		mapValue, ok := cacheModel.Value.(map[string]any)
+		if !ok {
+			t.Fatalf("expected map[string]any, got %T", cacheModel.Value)
+		}
+		if mapValue["name"] != "rm" || mapValue["count"] != float64(3) {
+			t.Fatalf("wrong value: %v", mapValue)
+		}
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_CodecLegacyValue(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 0)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// write raw value like previous versions did
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer redisClient.Close()
+	if err := redisClient.Set(ctx, "legacy-key", "legacy value", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Get returns the raw string
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("legacy-key"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "legacy value" {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used to compress values
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+	CompressionSnappy
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionSnappy:
+		return "snappy"
+	}
+	return fmt.Sprintf("compression(%d)", byte(c))
+}
+
+// compressionMarker prefixes compressed values, followed by the algorithm
+var compressionMarker = []byte("\x00cmp")
+
+// compressionHeaderSize is the size of marker and algorithm
+const compressionHeaderSize = 4 + 1
+
+// defaultMaxDecompressedSize limits decompressed values if not configured
+// otherwise
+const defaultMaxDecompressedSize int64 = 64 << 20
+
+// zstd encoder and decoder are safe for concurrent use of EncodeAll and
+// DecodeAll and expensive to create, so they are shared. Stores limiting
+// decompressed values to another size create their own decoder.
+var (
+	zstdEncoder, _       = zstd.NewWriter(nil)
+	sharedZstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(defaultMaxDecompressedSize)))
+)
+
+// zstdDecoder returns the decoder limited to the maximum decompressed size
+func (csr *cacheStoreRedis) zstdDecoder() (*zstd.Decoder, error) {
+	maxSize := csr.storeOptions.MaxDecompressedSize
+	if maxSize == defaultMaxDecompressedSize {
+		return sharedZstdDecoder, nil
+	}
+	csr.zstdOnce.Do(func() {
+		csr.zstd, csr.zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	})
+	return csr.zstd, csr.zstdErr
+}
+
+// compress compresses data with the configured algorithm if it exceeds the
+// threshold. Data is returned unchanged if compression does not pay off.
+func (csr *cacheStoreRedis) compress(data []byte) ([]byte, error) {
+	algorithm := csr.storeOptions.Compression
+	if algorithm == CompressionNone || len(data) < csr.storeOptions.CompressionThreshold {
+		return data, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	buf.Write(compressionMarker)
+	buf.WriteByte(byte(algorithm))
+	switch algorithm {
+	case CompressionGzip:
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("'%s' failed - failed to compress value: %w", csr.String(), err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("'%s' failed - failed to compress value: %w", csr.String(), err)
+		}
+	case CompressionZstd:
+		buf.Write(zstdEncoder.EncodeAll(data, nil))
+	case CompressionSnappy:
+		buf.Write(snappy.Encode(nil, data))
+	default:
+		return nil, fmt.Errorf("'%s' failed - unknown compression %s", csr.String(), algorithm)
+	}
+	if buf.Len() >= len(data) {
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress reverses compress. Values are decompressed regardless of the
+// configured algorithm, so that changing it keeps existing entries readable.
+// Values exceeding the maximum decompressed size are rejected.
+func (csr *cacheStoreRedis) decompress(data []byte) ([]byte, error) {
+	if len(data) < compressionHeaderSize || !bytes.HasPrefix(data, compressionMarker) {
+		return data, nil
+	}
+	algorithm := Compression(data[4])
+	compressed := data[compressionHeaderSize:]
+	maxSize := csr.storeOptions.MaxDecompressedSize
+	errTooLarge := fmt.Errorf("decompressed value exceeds %d bytes", maxSize)
+
+	var decompressed []byte
+	var err error
+	switch algorithm {
+	case CompressionGzip:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(compressed)); err == nil {
+			decompressed, err = io.ReadAll(io.LimitReader(r, maxSize+1))
+		}
+		if int64(len(decompressed)) > maxSize {
+			err = errTooLarge
+		}
+	case CompressionZstd:
+		var decoder *zstd.Decoder
+		if decoder, err = csr.zstdDecoder(); err == nil {
+			decompressed, err = decoder.DecodeAll(compressed, nil)
+		}
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+			err = errTooLarge
+		}
+	case CompressionSnappy:
+		var size int
+		if size, err = snappy.DecodedLen(compressed); err == nil && int64(size) > maxSize {
+			err = errTooLarge
+		}
+		if err == nil {
+			decompressed, err = snappy.Decode(nil, compressed)
+		}
+	default:
+		err = fmt.Errorf("unknown compression %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to decompress value: %w", csr.String(), err)
+	}
+	return decompressed, nil
+}
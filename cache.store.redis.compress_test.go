@@ -0,0 +1,155 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_Compression(t *testing.T) {
+	ctx := context.Background()
+
+	cryptoService, err := comby.NewCryptoService([]byte("12345678901234567890123456789012"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+
+	largeValue := strings.Repeat(`{"name":"readmodel","count":42},`, 4096)
+	for _, algorithm := range []store.Compression{store.CompressionGzip, store.CompressionZstd, store.CompressionSnappy} {
+		for _, encrypted := range []bool{false, true} {
+			opts := []store.Option{store.OptionWithCompression(algorithm, 1024)}
+			if encrypted {
+				opts = append(opts, store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(cryptoService)))
+			}
+
+			// setup and init store
+			cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1}, opts...)
+			if err := cacheStore.Init(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			// reset database
+			if err := cacheStore.Reset(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			for key, value := range map[string]string{"large": largeValue, "small": "small value"} {
+				if err := cacheStore.Set(ctx,
+					comby.CacheStoreSetOptionWithKeyValue(key, value),
+				); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// large values are compressed, small values are stored as they are
+			storedLarge := redisClient.Get(ctx, "large").Val()
+			if len(storedLarge) >= len(largeValue)/4 {
+				t.Fatalf("%s: expected compressed value, got %d bytes", algorithm, len(storedLarge))
+			}
+			if storedSmall := redisClient.Get(ctx, "small").Val(); !encrypted && !strings.Contains(storedSmall, "small value") {
+				t.Fatalf("%s: expected uncompressed value, got %q", algorithm, storedSmall)
+			}
+
+			// Get and List decompress transparently
+			if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("large")); err != nil {
+				t.Fatal(err)
+			} else if cacheModel.Value != largeValue {
+				t.Fatalf("%s: wrong value", algorithm)
+			}
+			if cacheModels, _, err := cacheStore.List(ctx); err != nil {
+				t.Fatal(err)
+			} else if len(cacheModels) != 2 {
+				t.Fatalf("%s: expected 2 entries, got %d", algorithm, len(cacheModels))
+			}
+
+			// close connection
+			if err := cacheStore.Close(ctx); err != nil {
+				t.Fatalf("failed to close connection: %v", err)
+			}
+		}
+	}
+
+	// stores without compression still read compressed values
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(cryptoService)),
+	)
+	if err := cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("large")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != largeValue {
+		t.Fatalf("wrong value")
+	}
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// invalid options
+	if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithCompression(store.Compression(42), 0),
+	); cacheStore != nil {
+		t.Fatalf("expected nil with invalid compression")
+	}
+	if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithMaxDecompressedSize(0),
+	); cacheStore != nil {
+		t.Fatalf("expected nil with invalid max decompressed size")
+	}
+}
+
+func TestCacheStore_MaxDecompressedSize(t *testing.T) {
+	ctx := context.Background()
+
+	largeValue := strings.Repeat("x", 64*1024)
+	for _, algorithm := range []store.Compression{store.CompressionGzip, store.CompressionZstd, store.CompressionSnappy} {
+		// setup and init stores writing and reading compressed values
+		writeStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+			store.OptionWithCompression(algorithm, 1024),
+		)
+		if err := writeStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		readStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+			store.OptionWithMaxDecompressedSize(32*1024),
+		)
+		if err := readStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		// reset database
+		if err := writeStore.Reset(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := writeStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue("large", largeValue),
+		); err != nil {
+			t.Fatal(err)
+		}
+		if cacheModel, err := writeStore.Get(ctx, comby.CacheStoreGetOptionWithKey("large")); err != nil {
+			t.Fatal(err)
+		} else if cacheModel.Value != largeValue {
+			t.Fatalf("%s: wrong value", algorithm)
+		}
+
+		// values exceeding the limit are rejected
+		if _, err := readStore.Get(ctx, comby.CacheStoreGetOptionWithKey("large")); !errors.Is(err, store.ErrCodec) {
+			t.Fatalf("%s: expected ErrCodec, got %v", algorithm, err)
+		}
+
+		// close connections
+		for _, cacheStore := range []store.CacheStoreRedis{writeStore, readStore} {
+			if err := cacheStore.Close(ctx); err != nil {
+				t.Fatalf("failed to close connection: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cipher encrypts and decrypts values, e.g. comby.CryptoService
+type Cipher interface {
+	Encrypt(data []byte) ([]byte, error)
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// TenantKeyProvider returns the cipher of a tenant. Once the key of a tenant
+// is destroyed, the provider returns an error or a nil cipher and its entries
+// can no longer be decrypted (crypto-shredding).
+type TenantKeyProvider func(tenantUuid string) (Cipher, error)
+
+// Keyring holds the key encrypting new values and retired keys which are
+// still accepted for decryption
+type Keyring struct {
+	CurrentKeyID string
+	Keys         map[string]Cipher
+}
+
+// envelopeMarker prefixes values encrypted with a key ID, followed by the
+// envelope version, the length of the key ID, the key ID and the ciphertext
+var envelopeMarker = []byte("\x00enc")
+
+const envelopeVersion byte = 1
+
+// tenantKeyIDPrefix prefixes the key IDs of envelopes encrypted with the key
+// of a tenant
+const tenantKeyIDPrefix = "tenant:"
+
+// sealEnvelope encrypts data using cipher identified by keyID
+func sealEnvelope(keyID string, cipher Cipher, data []byte) ([]byte, error) {
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("key id '%s' too long", keyID)
+	}
+	ciphertext, err := cipher.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	envelope := make([]byte, 0, len(envelopeMarker)+2+len(keyID)+len(ciphertext))
+	envelope = append(envelope, envelopeMarker...)
+	envelope = append(envelope, envelopeVersion, byte(len(keyID)))
+	envelope = append(envelope, keyID...)
+	return append(envelope, ciphertext...), nil
+}
+
+// parseEnvelope returns key ID and ciphertext of an envelope, ok is false
+// if data is no envelope
+func parseEnvelope(data []byte) (keyID string, ciphertext []byte, ok bool) {
+	if !bytes.HasPrefix(data, envelopeMarker) || len(data) < len(envelopeMarker)+2 {
+		return "", nil, false
+	}
+	data = data[len(envelopeMarker):]
+	if data[0] != envelopeVersion || len(data) < 2+int(data[1]) {
+		return "", nil, false
+	}
+	return string(data[2 : 2+data[1]]), data[2+data[1]:], true
+}
+
+// openEnvelope decrypts an envelope using the key it was encrypted with,
+// ok is false if data is no envelope
+func (csr *cacheStoreRedis) openEnvelope(data []byte) ([]byte, bool, error) {
+	keyID, ciphertext, ok := parseEnvelope(data)
+	if !ok {
+		return nil, false, nil
+	}
+	cipher, err := csr.envelopeCipher(keyID)
+	if err != nil {
+		return nil, true, err
+	}
+	plaintext, err := cipher.Decrypt(ciphertext)
+	return plaintext, true, err
+}
+
+// envelopeCipher returns the cipher identified by keyID
+func (csr *cacheStoreRedis) envelopeCipher(keyID string) (Cipher, error) {
+	if tenantUuid, ok := strings.CutPrefix(keyID, tenantKeyIDPrefix); ok && csr.storeOptions.TenantKeys != nil {
+		cipher, err := csr.storeOptions.TenantKeys.Provider(tenantUuid)
+		if err == nil && cipher == nil {
+			err = fmt.Errorf("no key for tenant '%s'", tenantUuid)
+		}
+		return cipher, err
+	}
+	if keyring := csr.storeOptions.Keyring; keyring != nil {
+		if cipher, ok := keyring.Keys[keyID]; ok {
+			return cipher, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id '%s'", keyID)
+}
+
+// encryptionKey returns key ID and cipher encrypting the value of key: the
+// key of its tenant, the current key of the keyring or the comby crypto
+// service without key ID. Cipher is nil if values are not encrypted.
+func (csr *cacheStoreRedis) encryptionKey(key string) (string, Cipher, error) {
+	if tenantKeys := csr.storeOptions.TenantKeys; tenantKeys != nil {
+		if tenantUuid, ok := tenantKeys.Resolver(key); ok {
+			cipher, err := tenantKeys.Provider(tenantUuid)
+			if err == nil && cipher == nil {
+				err = fmt.Errorf("no key for tenant '%s'", tenantUuid)
+			}
+			return tenantKeyIDPrefix + tenantUuid, cipher, err
+		}
+	}
+	if keyring := csr.storeOptions.Keyring; keyring != nil {
+		return keyring.CurrentKeyID, keyring.Keys[keyring.CurrentKeyID], nil
+	}
+	if csr.options.CryptoService != nil {
+		return "", csr.options.CryptoService, nil
+	}
+	return "", nil, nil
+}
+
+// bindingMarker prefixes plaintexts bound to their cache key, followed by
+// the length of the key, the key and the value. Binding is verified after
+// decryption, so ciphertexts copied to another key are rejected.
+var bindingMarker = []byte("\x00key")
+
+// bindKey prefixes data with key before encryption
+func bindKey(key string, data []byte) []byte {
+	bound := make([]byte, 0, len(bindingMarker)+binary.MaxVarintLen64+len(key)+len(data))
+	bound = append(bound, bindingMarker...)
+	bound = binary.AppendUvarint(bound, uint64(len(key)))
+	bound = append(bound, key...)
+	return append(bound, data...)
+}
+
+// unbindKey returns the key and value of decrypted data, ok is false if
+// data is not bound to a key
+func unbindKey(data []byte) (key string, value []byte, ok bool) {
+	if !bytes.HasPrefix(data, bindingMarker) {
+		return "", nil, false
+	}
+	data = data[len(bindingMarker):]
+	keyLen, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < keyLen {
+		return "", nil, false
+	}
+	return string(data[n : n+int(keyLen)]), data[n+int(keyLen):], true
+}
+
+// verifyKey returns the value of decrypted data if it is bound to key.
+// Unbound data written by previous versions is accepted only if allowed.
+func (csr *cacheStoreRedis) verifyKey(key string, data []byte) ([]byte, error) {
+	boundKey, value, ok := unbindKey(data)
+	switch {
+	case !ok && csr.storeOptions.AllowUnboundCiphertext:
+		return data, nil
+	case !ok:
+		return nil, fmt.Errorf("'%s' failed - encrypted value is not bound to its key", csr.String())
+	case boundKey != key:
+		return nil, fmt.Errorf("'%s' failed - encrypted value is bound to another key", csr.String())
+	}
+	return value, nil
+}
+
+// encrypted reports whether the value of key is encrypted
+func (csr *cacheStoreRedis) encrypted(key string) bool {
+	if tenantKeys := csr.storeOptions.TenantKeys; tenantKeys != nil {
+		if _, ok := tenantKeys.Resolver(key); ok {
+			return true
+		}
+	}
+	return csr.storeOptions.Keyring != nil || csr.options.CryptoService != nil
+}
+
+// reencryptScript replaces the value of a key if unchanged, keeping its TTL.
+// Given the usage keys of its tenant, the size of the entry is updated. The
+// size changes by a few bytes only, so limits are not enforced and rotating
+// keys never evicts entries.
+//
+// KEYS: key, optionally tenant index, tenant sizes, tenant bytes
+// ARGV: expected value, new value
+var reencryptScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "KEEPTTL")
+if #KEYS == 4 and redis.call("ZSCORE", KEYS[2], KEYS[1]) then
+	local size = string.len(ARGV[2])
+	local previous = tonumber(redis.call("HGET", KEYS[3], KEYS[1]) or "0")
+	redis.call("HSET", KEYS[3], KEYS[1], size)
+	redis.call("INCRBY", KEYS[4], size - previous)
+end
+return 1
+`)
+
+func (csr *cacheStoreRedis) Reencrypt(ctx context.Context) (int64, error) {
+	if csr.storeOptions.Keyring == nil && csr.storeOptions.TenantKeys == nil {
+		return 0, fmt.Errorf("'%s' failed - no keyring or tenant keys configured", csr.String())
+	}
+
+	// entries and stale copies hold encrypted values
+	staleKeyPrefix := csr.internalKey("stale", "")
+	var rewritten int64
+	err := csr.scan(ctx, csr.redisClient, escapePattern(csr.storeOptions.Namespace)+"*", func(keys []string) error {
+		valueKeys := keys[:0]
+		for _, key := range keys {
+			if !csr.isInternal(key) || strings.HasPrefix(key, staleKeyPrefix) {
+				valueKeys = append(valueKeys, key)
+			}
+		}
+
+		pipe := csr.redisClient.Pipeline()
+		getCmds := make([]*redis.StringCmd, len(valueKeys))
+		cmders := make([]redis.Cmder, len(valueKeys))
+		for i, key := range valueKeys {
+			getCmds[i] = pipe.Get(ctx, key)
+			cmders[i] = getCmds[i]
+		}
+		// missing keys and other types are skipped
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) && !hasCmdErrors(cmders) {
+			return err
+		}
+
+		pipe = csr.redisClient.Pipeline()
+		var cmds []*redis.Cmd
+		for i, redisKey := range valueKeys {
+			value, err := getCmds[i].Bytes()
+			if err != nil {
+				continue
+			}
+			key := csr.unkey(redisKey)
+			if strings.HasPrefix(redisKey, staleKeyPrefix) {
+				key = strings.TrimPrefix(redisKey, staleKeyPrefix)
+			}
+			targetKeyID, _, err := csr.encryptionKey(key)
+			if err != nil || !csr.encrypted(key) {
+				continue
+			}
+			decryptedBytes, err := csr.decryptRaw(value)
+			if err != nil {
+				continue
+			}
+
+			// values encrypted with the target key and bound are up to date
+			keyID, _, _ := parseEnvelope(value)
+			if boundKey, _, bound := unbindKey(decryptedBytes); bound && boundKey == key && keyID == targetKeyID {
+				continue
+			}
+			plaintext, err := csr.verifyKey(key, decryptedBytes)
+			if err != nil {
+				continue
+			}
+			ciphertext, err := csr.encryptValue(key, plaintext)
+			if err != nil {
+				return err
+			}
+			// entries subject to a quota keep their usage accurate
+			scriptKeys := []string{redisKey}
+			if tenantUuid, ok := csr.quotaTenant(key); ok && !strings.HasPrefix(redisKey, staleKeyPrefix) {
+				scriptKeys = append(scriptKeys, csr.quotaKeys(tenantUuid)[:3]...)
+			}
+			cmds = append(cmds, reencryptScript.Eval(ctx, pipe, scriptKeys, value, ciphertext))
+		}
+		if len(cmds) == 0 {
+			return nil
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		for _, cmd := range cmds {
+			if n, _ := cmd.Int64(); n == 1 {
+				rewritten++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rewritten, fmt.Errorf("'%s' failed - failed to re-encrypt: %w", csr.String(), err)
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,410 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_KeyRotation(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	newCryptoService := func(key string) *comby.CryptoService {
+		cryptoService, err := comby.NewCryptoService([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cryptoService
+	}
+	legacyKey := newCryptoService("00000000000000000000000000000000")
+	key1 := newCryptoService("11111111111111111111111111111111")
+	key2 := newCryptoService("22222222222222222222222222222222")
+
+	// entries written by the comby crypto service without envelope
+	legacyStore := store.NewCacheStoreRedis("localhost:6379", "", 1,
+		comby.CacheStoreOptionWithCryptoService(legacyKey),
+	)
+	if err = legacyStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacyStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacyStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("legacy", "legacyValue"),
+		comby.CacheStoreSetOptionWithExpiration(time.Hour),
+	); err != nil {
+		t.Fatal(err)
+	}
+	legacyStore.Close(ctx)
+
+	// entries written with key1
+	store1 := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(legacyKey)),
+		store.OptionWithEncryptionKey("key1", key1),
+	)
+	if err = store1.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := store1.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("key%d", i), i),
+			comby.CacheStoreSetOptionWithExpiration(time.Hour),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cacheModel, err := store1.Get(ctx, comby.CacheStoreGetOptionWithKey("legacy")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "legacyValue" {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+	store1.Close(ctx)
+
+	// rotate to key2, key1 is retired
+	store2 := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(legacyKey)),
+		store.OptionWithEncryptionKey("key2", key2),
+		store.OptionWithRetiredEncryptionKey("key1", key1),
+	)
+	if err = store2.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModels, _, err := store2.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 6 {
+		t.Fatalf("expected 6 readable entries, got %d", len(cacheModels))
+	}
+
+	// re-encrypt everything under key2, keeping TTLs
+	if rewritten, err := store2.Reencrypt(ctx); err != nil {
+		t.Fatal(err)
+	} else if rewritten != 6 {
+		t.Fatalf("expected 6 rewritten entries, got %d", rewritten)
+	}
+	if rewritten, err := store2.Reencrypt(ctx); err != nil {
+		t.Fatal(err)
+	} else if rewritten != 0 {
+		t.Fatalf("expected 0 rewritten entries, got %d", rewritten)
+	}
+	if cacheModel, err := store2.Get(ctx, comby.CacheStoreGetOptionWithKey("key3")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != 3 || cacheModel.ExpiredAt < time.Now().Add(50*time.Minute).UnixNano() {
+		t.Fatalf("wrong cache model: %+v", cacheModel)
+	}
+	store2.Close(ctx)
+
+	// key2 alone reads all entries
+	store3 := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithEncryptionKey("key2", key2),
+	)
+	if err = store3.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModels, _, err := store3.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 6 {
+		t.Fatalf("expected 6 readable entries, got %d", len(cacheModels))
+	}
+	store3.Close(ctx)
+
+	// unknown key id
+	store4 := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithEncryptionKey("key1", key1),
+	)
+	if err = store4.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store4.Get(ctx, comby.CacheStoreGetOptionWithKey("key3")); err == nil {
+		t.Fatalf("expected error with unknown key id")
+	}
+	store4.Close(ctx)
+
+	// keyring requires a current key
+	store5 := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithRetiredEncryptionKey("key1", key1),
+	)
+	if err := store5.Init(ctx); err == nil {
+		t.Fatalf("expected error without current key")
+	}
+}
+
+func TestCacheStore_ReencryptQuota(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	key1, err := comby.NewCryptoService([]byte("11111111111111111111111111111111"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := comby.NewCryptoService([]byte("22222222222222222222222222222222"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newStore := func(opts ...store.Option) store.CacheStoreRedis {
+		opts = append(opts,
+			store.OptionWithTenantIndex(store.TenantResolverDelimiter("-")),
+			store.OptionWithTenantQuota(0, 0, store.QuotaPolicyReject),
+		)
+		cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3}, opts...)
+		if err = cacheStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return cacheStore
+	}
+
+	// entries of a tenant written with key1
+	store1 := newStore(store.OptionWithEncryptionKey("key1", key1))
+	if err := store1.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"tenant1-key1", "tenant1-key2"} {
+		if err := store1.Set(ctx, comby.CacheStoreSetOptionWithKeyValue(key, key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store1.Close(ctx)
+
+	// rotating to a key with a longer key id changes the stored sizes
+	store2 := newStore(
+		store.OptionWithEncryptionKey("rotated-key2", key2),
+		store.OptionWithRetiredEncryptionKey("key1", key1),
+	)
+	if rewritten, err := store2.Reencrypt(ctx); err != nil {
+		t.Fatal(err)
+	} else if rewritten != 2 {
+		t.Fatalf("expected 2 rewritten entries, got %d", rewritten)
+	}
+
+	// usage reflects the rewritten values
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 3})
+	defer redisClient.Close()
+	var stored int64
+	for _, key := range []string{"tenant1-key1", "tenant1-key2"} {
+		size, err := redisClient.StrLen(ctx, key).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored += size
+	}
+	if usages, err := store2.TenantUsage(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if usages[0].Keys != 2 || usages[0].Bytes != stored {
+		t.Fatalf("expected 2 keys and %d bytes, got %+v", stored, usages[0])
+	}
+
+	// close connection
+	if err := store2.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_KeyBinding(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	cryptoService, err := comby.NewCryptoService([]byte("01234567890123456789012345678901"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setup and init store with crypto service
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 1,
+		comby.CacheStoreOptionWithCryptoService(cryptoService),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-secret", "secret1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// copy the ciphertext to a key of another tenant
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+	ciphertext, err := redisClient.Get(ctx, "tenant1-secret").Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := redisClient.Set(ctx, "tenant2-secret", ciphertext, time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("tenant2-secret")); err == nil {
+		t.Fatalf("expected error reading a ciphertext of another key")
+	}
+	if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("tenant1-secret")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "secret1" {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+
+	// ciphertext written by previous versions is not bound
+	unbound, err := cryptoService.Encrypt([]byte(`"unbound"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := redisClient.Set(ctx, "tenant1-unbound", unbound, time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("tenant1-unbound")); err == nil {
+		t.Fatalf("expected error reading unbound ciphertext")
+	}
+	cacheStore.Close(ctx)
+
+	// unbound ciphertext is accepted during migration and bound by Reencrypt
+	cacheStore = store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithEncryptionKey("key1", cryptoService),
+		store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(cryptoService)),
+		store.OptionWithUnboundCiphertext(),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("tenant1-unbound")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "unbound" {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+	if _, err := cacheStore.Reencrypt(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cacheStore.Close(ctx)
+
+	cacheStore = store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithEncryptionKey("key1", cryptoService),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("tenant1-unbound")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "unbound" {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_TenantKeys(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	tenant1 := "11111111-1111-4111-8111-111111111111"
+	tenant2 := "22222222-2222-4222-8222-222222222222"
+	tenantKeys := map[string]store.Cipher{}
+	for tenantUuid, key := range map[string]string{
+		tenant1: "11111111111111111111111111111111",
+		tenant2: "22222222222222222222222222222222",
+	} {
+		cryptoService, err := comby.NewCryptoService([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenantKeys[tenantUuid] = cryptoService
+	}
+	// providers report destroyed keys either by error or by no cipher
+	providerErr := true
+	provider := func(tenantUuid string) (store.Cipher, error) {
+		cipher, ok := tenantKeys[tenantUuid]
+		if !ok && providerErr {
+			return nil, fmt.Errorf("key of tenant %s destroyed", tenantUuid)
+		}
+		return cipher, nil
+	}
+
+	// setup and init store with tenant keys
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithTenantKeyProvider(provider, nil),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenantUuid := range []string{tenant1, tenant2} {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(tenantUuid+"-key", "value of "+tenantUuid),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("global", "plain"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// values are encrypted with the key of their tenant
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+	if value := redisClient.Get(ctx, tenant1+"-key").Val(); !strings.Contains(value, "tenant:"+tenant1) {
+		t.Fatalf("expected envelope of tenant key")
+	}
+	if cacheModels, _, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid(tenant1),
+	); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 1 || cacheModels[0].Value != "value of "+tenant1 {
+		t.Fatalf("wrong entries: %v", cacheModels)
+	}
+
+	// destroying the key of tenant1 shreds its entries
+	delete(tenantKeys, tenant1)
+	if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(tenant1+"-key")); err == nil {
+		t.Fatalf("expected error reading shredded entry")
+	}
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue(tenant1+"-key2", "value"),
+	); err == nil {
+		t.Fatalf("expected error writing without tenant key")
+	}
+	if cacheModels, _, err := cacheStore.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 2 {
+		t.Fatalf("expected 2 readable entries, got %d", len(cacheModels))
+	}
+	if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(tenant2+"-key")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "value of "+tenant2 {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+
+	// destroyed keys without error are shredded as well
+	providerErr = false
+	if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(tenant1+"-key")); !errors.Is(err, store.ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt reading shredded entry, got %v", err)
+	}
+	if cacheModels, _, err := cacheStore.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 2 {
+		t.Fatalf("expected 2 readable entries, got %d", len(cacheModels))
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors returned by the store are classified using these sentinels, which
+// wrap the cause, e.g. errors.Is(err, store.ErrTimeout).
+var (
+	// ErrUnavailable indicates Redis being unreachable, including the open
+	// circuit of the circuit breaker
+	ErrUnavailable = errors.New("redis unavailable")
+	// ErrTimeout indicates a call exceeding its deadline
+	ErrTimeout = errors.New("redis timeout")
+	// ErrDecrypt indicates a value which could not be decrypted or is not
+	// bound to its key
+	ErrDecrypt = errors.New("failed to decrypt")
+	// ErrCodec indicates a value which could not be encoded, decoded,
+	// compressed or decompressed
+	ErrCodec = errors.New("codec failed")
+	// ErrWrongType indicates a key holding a value of another Redis type
+	ErrWrongType = errors.New("wrong type")
+)
+
+// transientServerErrors are prefixes of errors returned by servers which
+// are temporarily unable to serve, e.g. during failover
+var transientServerErrors = []string{"LOADING ", "READONLY ", "CLUSTERDOWN ", "TRYAGAIN ", "MASTERDOWN "}
+
+// classifiedError wraps the cause with its class, keeping the message of
+// the cause
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+func classified(class, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// classify wraps errors returned by go-redis with their class. Misses,
+// canceled calls and errors returned by the server other than WRONGTYPE and
+// transient states are returned as they are, as go-redis matches them by
+// type, e.g. NOSCRIPT.
+func classify(err error) error {
+	var classifiedErr *classifiedError
+	switch {
+	case err == nil, errors.Is(err, redis.Nil), errors.As(err, &classifiedErr):
+		return err
+	case errors.Is(err, context.Canceled), errors.Is(err, redis.ErrClosed):
+		return err
+	case errors.Is(err, ErrCircuitOpen):
+		return classified(ErrUnavailable, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return classified(ErrTimeout, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return classified(ErrTimeout, err)
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		msg := strings.TrimPrefix(redisErr.Error(), "ERR ")
+		if strings.HasPrefix(msg, "WRONGTYPE ") {
+			return classified(ErrWrongType, err)
+		}
+		for _, prefix := range transientServerErrors {
+			if strings.HasPrefix(msg, prefix) {
+				return classified(ErrUnavailable, err)
+			}
+		}
+		return err
+	}
+	return classified(ErrUnavailable, err)
+}
+
+// RetryPolicy configures retries of failed calls
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first call
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt, doubled for
+	// every further attempt
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// RetryOn are the error classes retried, e.g. ErrUnavailable
+	RetryOn []error
+}
+
+// backoff returns the delay before the given attempt, randomized between
+// half and the full exponential backoff
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff << (attempt - 2)
+	if backoff > p.MaxBackoff || backoff <= 0 {
+		backoff = p.MaxBackoff
+	}
+	if half := int64(backoff / 2); half > 0 {
+		return time.Duration(half + rand.Int64N(half+1))
+	}
+	return backoff
+}
+
+// retryable reports whether a call failing with err is attempted again.
+// Calls rejected by the open circuit are not retried.
+func (p *RetryPolicy) retryable(attempt int, err error) bool {
+	if err == nil || attempt >= p.MaxAttempts || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	for _, class := range p.RetryOn {
+		if errors.Is(err, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonIdempotentCommands may apply their effect twice if an attempt failed
+// after Redis executed it. Calls containing them are not retried.
+var nonIdempotentCommands = map[string]bool{
+	"incr": true, "incrby": true, "incrbyfloat": true, "decr": true, "decrby": true,
+	"hincrby": true, "hincrbyfloat": true, "zincrby": true,
+	"append": true, "lpush": true, "rpush": true, "lpop": true, "rpop": true, "spop": true, "getdel": true,
+}
+
+// idempotentScripts are the sources and hashes of scripts created using
+// newIdempotentScript
+var idempotentScripts = map[string]bool{}
+
+// newIdempotentScript returns a script which can be attempted again without
+// changing its outcome. Other scripts, e.g. the quota scripts counting the
+// usage of a tenant, are not retried.
+func newIdempotentScript(src string) *redis.Script {
+	script := redis.NewScript(src)
+	idempotentScripts[src] = true
+	idempotentScripts[script.Hash()] = true
+	return script
+}
+
+// idempotent reports whether cmd can be attempted again without changing
+// its outcome
+func idempotent(cmd redis.Cmder) bool {
+	switch name := cmd.Name(); name {
+	case "eval", "evalsha", "eval_ro", "evalsha_ro":
+		script, _ := cmd.Args()[1].(string)
+		return idempotentScripts[script]
+	case "fcall", "fcall_ro":
+		return false
+	case "set":
+		// SET NX fails if a previous attempt set the key, e.g. acquiring a
+		// lock
+		for _, arg := range cmd.Args()[1:] {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "nx") {
+				return false
+			}
+		}
+	default:
+		return !nonIdempotentCommands[name]
+	}
+	return true
+}
+
+type noRetryKey struct{}
+
+// withoutRetries returns a context whose calls are not retried by the retry
+// hook, e.g. for callers retrying on their own
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// retryHook is a go-redis hook classifying errors and retrying failed calls
+// according to the retry policy, if set. Commands which are not idempotent
+// and pipelines containing them are not retried.
+type retryHook struct {
+	policy *RetryPolicy
+}
+
+// Make sure it implements interfaces
+var _ redis.Hook = (*retryHook)(nil)
+
+func (h *retryHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *retryHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := h.do(ctx, idempotent(cmd), func() error {
+			return next(ctx, cmd)
+		})
+		if err != nil && cmd.Err() != nil {
+			cmd.SetErr(classify(cmd.Err()))
+		}
+		return err
+	}
+}
+
+func (h *retryHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		retry := true
+		for _, cmd := range cmds {
+			retry = retry && idempotent(cmd)
+		}
+		err := h.do(ctx, retry, func() error {
+			return next(ctx, cmds)
+		})
+		for _, cmd := range cmds {
+			if cmd.Err() != nil {
+				cmd.SetErr(classify(cmd.Err()))
+			}
+		}
+		return err
+	}
+}
+
+// do calls fn until it succeeds or the policy gives up and returns the
+// classified error of the last attempt. fn is called once unless retry is
+// set.
+func (h *retryHook) do(ctx context.Context, retry bool, fn func() error) error {
+	err := classify(fn())
+	if h.policy == nil || !retry || ctx.Value(noRetryKey{}) != nil {
+		return err
+	}
+	for attempt := 2; h.policy.retryable(attempt-1, err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(h.policy.backoff(attempt)):
+		}
+		err = classify(fn())
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_ErrorClasses(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	cryptoService1, err := comby.NewCryptoService([]byte("11111111111111111111111111111111"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cryptoService2, err := comby.NewCryptoService([]byte("22222222222222222222222222222222"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1})
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+
+	// key holding another Redis type, the cause is kept
+	if err := redisClient.LPush(ctx, "list", "item").Err(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("list"))
+	var redisErr redis.Error
+	if !errors.Is(err, store.ErrWrongType) || !errors.As(err, &redisErr) {
+		t.Fatalf("expected ErrWrongType wrapping the redis error, got %v", err)
+	}
+
+	// value not decodable by the codec
+	if err := redisClient.Set(ctx, "invalid", `{"_t":"unknown","_v":1}`, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("invalid")); !errors.Is(err, store.ErrCodec) {
+		t.Fatalf("expected ErrCodec, got %v", err)
+	}
+
+	// expired deadline
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer cancel()
+	<-timeoutCtx.Done()
+	if _, err := cacheStore.Get(timeoutCtx, comby.CacheStoreGetOptionWithKey("key")); !errors.Is(err, store.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// value encrypted with another key
+	encryptedStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(cryptoService1)),
+	)
+	if err = encryptedStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptedStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("secret", "value")); err != nil {
+		t.Fatal(err)
+	}
+	encryptedStore.Close(ctx)
+	encryptedStore = store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithCacheStoreOptions(comby.CacheStoreOptionWithCryptoService(cryptoService2)),
+	)
+	if err = encryptedStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encryptedStore.Get(ctx, comby.CacheStoreGetOptionWithKey("secret")); !errors.Is(err, store.ErrDecrypt) {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+	encryptedStore.Close(ctx)
+
+	// unreachable server
+	unavailableStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	if err = unavailableStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unavailableStore.Get(ctx, comby.CacheStoreGetOptionWithKey("key")); !errors.Is(err, store.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	unavailableStore.Close(ctx)
+}
+
+func TestCacheStore_RetryPolicy(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// connections fail until the given number of dials failed
+	var dials, failingDials atomic.Int64
+	var down atomic.Bool
+	redisOptions := &redis.Options{
+		Addr: "localhost:6379",
+		DB:   1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if dials.Add(1) <= failingDials.Load() {
+				return nil, errors.New("connection refused")
+			}
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &flakyConn{Conn: conn, down: &down}, nil
+		},
+	}
+
+	// setup and init store retrying up to 3 attempts
+	cacheStore := store.NewCacheStoreRedisWithOptions(redisOptions,
+		store.OptionWithRetryPolicy(3, 10*time.Millisecond, 50*time.Millisecond),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// transient failures are retried
+	failingDials.Store(2)
+	if err := cacheStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "value")); err != nil {
+		t.Fatalf("expected call to succeed after retries: %v", err)
+	}
+	if n := dials.Load(); n != 3 {
+		t.Fatalf("expected 3 dials, got %d", n)
+	}
+
+	// calls fail once all attempts failed
+	dials.Store(0)
+	failingDials.Store(3)
+	down.Store(true)
+	_, err = cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("key"))
+	if !errors.Is(err, store.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	down.Store(false)
+
+	// errors of other classes are returned classified
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+	if err := redisClient.LPush(ctx, "list", "item").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("list")); !errors.Is(err, store.ErrWrongType) {
+		t.Fatalf("expected ErrWrongType, got %v", err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// invalid options
+	if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithRetryPolicy(0, time.Millisecond, time.Second),
+	); cacheStore != nil {
+		t.Fatalf("expected nil with invalid retry policy")
+	}
+}
+
+func TestCacheStore_RetryPolicyIdempotent(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// connections fail until the given number of dials failed
+	var dials, failingDials atomic.Int64
+	redisOptions := &redis.Options{
+		Addr: "localhost:6379",
+		DB:   1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if dials.Add(1) <= failingDials.Load() {
+				return nil, errors.New("connection refused")
+			}
+			return net.Dial(network, addr)
+		},
+	}
+
+	// the connection check retries on its own
+	failingDials.Store(10)
+	cacheStore := store.NewCacheStoreRedisWithOptions(redisOptions,
+		store.OptionWithRetryPolicy(3, time.Millisecond, time.Millisecond),
+		store.OptionWithConnectCheck(2, time.Millisecond),
+	)
+	if err = cacheStore.Init(ctx); err == nil {
+		t.Fatalf("expected connection check to fail")
+	}
+	if n := dials.Load(); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+
+	// setup and init store retrying up to 3 attempts
+	dials.Store(0)
+	failingDials.Store(0)
+	cacheStore = store.NewCacheStoreRedisWithOptions(redisOptions,
+		store.OptionWithRetryPolicy(3, time.Millisecond, time.Millisecond),
+		store.OptionWithTenantIndex(store.TenantResolverDelimiter("-")),
+		store.OptionWithTenantQuota(10, 0, store.QuotaPolicyEvictOldest),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// writes subject to a quota are not retried
+	failingDials.Store(2)
+	err = cacheStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("tenant1-key", "value"))
+	if !errors.Is(err, store.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if n := dials.Load(); n != 1 {
+		t.Fatalf("expected 1 dial, got %d", n)
+	}
+
+	// deletes are retried and fail once all attempts failed
+	dials.Store(0)
+	failingDials.Store(3)
+	err = cacheStore.Delete(ctx, comby.CacheStoreDeleteOptionWithKey("key"))
+	if !errors.Is(err, store.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if n := dials.Load(); n != 3 {
+		t.Fatalf("expected 3 dials, got %d", n)
+	}
+
+	// deletes honour the context of the caller
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := cacheStore.Delete(canceledCtx, comby.CacheStoreDeleteOptionWithKey("key")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -3,43 +3,197 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gradientzero/comby/v2"
+	"github.com/klauspost/compress/zstd"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
+// CacheStoreRedis is a comby.CacheStore backed by Redis providing additional
+// redis specific functionality.
+type CacheStoreRedis interface {
+	comby.CacheStore
+
+	// ListWithOptions lists cache entries paginated and ordered as requested.
+	// The returned total is the number of matching keys, an upper bound of
+	// the entries returned across all pages: entries expiring or failing to
+	// decode before their page is fetched are counted but skipped.
+	ListWithOptions(ctx context.Context, opts ...ListOption) ([]*comby.CacheModel, int64, error)
+
+	// HealthCheck pings the server and reports latency, version and role,
+	// e.g. for readiness probes.
+	HealthCheck(ctx context.Context) (*HealthStatus, error)
+
+	// GetMany returns the entries of the given keys within a single round
+	// trip. Results are in the order of keys, missing keys have no
+	// CacheModel and keys failing to decode carry their error.
+	GetMany(ctx context.Context, keys ...string) ([]*BatchResult, error)
+	// SetMany writes all items within a single round trip, each with its
+	// own expiration. Results carry the error of items which failed.
+	SetMany(ctx context.Context, items ...*BatchItem) ([]*BatchResult, error)
+	// DeleteMany removes the given keys and returns the number of removed keys
+	DeleteMany(ctx context.Context, keys ...string) (int64, error)
+
+	// GetOrLoad returns the entry of key or calls loader on a miss and
+	// stores the loaded value for ttl. Concurrent loads of the same key are
+	// coalesced within the process and, using OptionWithLoadLock, across
+	// instances sharing the Redis server. A caller canceling its context
+	// returns early, the shared load continues up to OptionWithLoadTimeout.
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader) (*comby.CacheModel, error)
+
+	// SetWithOptions writes a cache entry, supporting redis specific options
+	// such as a soft TTL.
+	SetWithOptions(ctx context.Context, opts ...SetOption) error
+	// GetEntry returns the entry of key including whether its soft TTL has
+	// passed, nil if it does not exist. Like Get it triggers a background
+	// refresh of stale entries.
+	GetEntry(ctx context.Context, key string) (*Entry, error)
+
+	// InvalidateTags atomically deletes all entries tagged with any of the
+	// given tags using SetOptionWithTags and returns the number of deleted
+	// entries. Requires OptionWithTags, not supported on Redis Cluster.
+	InvalidateTags(ctx context.Context, tags ...string) (int64, error)
+
+	// DeleteByTenant removes all entries of the given tenant, i.e. keys
+	// prefixed "tenantUuid-" or the keys of its index if the tenant index is
+	// enabled, and returns the number of removed entries.
+	DeleteByTenant(ctx context.Context, tenantUuid string) (int64, error)
+	// CountByTenant returns the number of entries of the given tenant, read
+	// from the tenant index if enabled using OptionWithTenantIndex.
+	CountByTenant(ctx context.Context, tenantUuid string) (int64, error)
+	// TenantUsage reports keys and bytes used by the given tenants, or all
+	// tenants if none given, along with their limits. Requires quotas
+	// enabled using OptionWithTenantQuota.
+	TenantUsage(ctx context.Context, tenantUuids ...string) ([]*TenantUsage, error)
+
+	// Reencrypt rewrites all entries not encrypted with the key of their
+	// tenant or the current key of the keyring, or not bound to their key,
+	// keeping their TTL. It returns the number of rewritten entries, entries
+	// which can not be decrypted are skipped.
+	Reencrypt(ctx context.Context) (int64, error)
+
+	// Stats reports hits and misses of Get per tier, i.e. the near cache
+	// enabled using OptionWithNearCache and Redis.
+	Stats() *CacheStats
+
+	// CircuitState returns the state of the circuit breaker enabled using
+	// OptionWithCircuitBreaker, always CircuitClosed if disabled.
+	CircuitState() CircuitState
+}
+
+// internalKeyPrefix prefixes all keys maintained by the store itself
+const internalKeyPrefix = "__comby:"
+
 type cacheStoreRedis struct {
 	options      comby.CacheStoreOptions
-	redisClient  *redis.Client
+	storeOptions Options
+	redisClient  redis.UniversalClient
 	redisOptions *redis.Options
+	// clusterOptions are set instead of redisOptions for Redis Cluster
+	clusterOptions *redis.ClusterOptions
+	// failoverOptions are set instead of redisOptions for Redis Sentinel
+	failoverOptions *redis.FailoverOptions
+	// loadGroup coalesces concurrent loads of GetOrLoad
+	loadGroup singleflight.Group
+	// refreshing holds the keys currently refreshed in background
+	refreshing sync.Map
+	// refreshWG tracks running background refreshes
+	refreshWG sync.WaitGroup
+	// readClient serves Get and List, it equals redisClient unless reads
+	// are routed to replicas
+	readClient redis.UniversalClient
+	// nearCache serves Get in process if enabled using OptionWithNearCache
+	nearCache *nearCache
+	// nearPubSub receives invalidations published by other instances
+	nearPubSub *redis.PubSub
+	// nearOrigin identifies invalidations published by this instance
+	nearOrigin string
+	nearDone   chan struct{}
+	// trackingClient holds the connection receiving invalidations of
+	// client tracking
+	trackingClient redis.UniversalClient
+	nearWG         sync.WaitGroup
+	// redisHits and redisMisses count Get served by Redis
+	redisHits, redisMisses atomic.Uint64
+	// breaker rejects calls while Redis is unavailable if enabled
+	breaker *circuitBreaker
+	// fallbackKeys are the keys written to the fallback store while the
+	// circuit was open, fallbackReset is set if it was reset
+	fallbackMu    sync.Mutex
+	fallbackKeys  map[string]struct{}
+	fallbackReset bool
+	fallbackUsed  bool
+	// fallbackWG tracks running reconciliations after the circuit closed
+	fallbackWG sync.WaitGroup
+	// zstd decodes values if MaxDecompressedSize is not the default
+	zstd     *zstd.Decoder
+	zstdErr  error
+	zstdOnce sync.Once
 }
 
 // Make sure it implements interfaces
 var _ comby.CacheStore = (*cacheStoreRedis)(nil)
+var _ CacheStoreRedis = (*cacheStoreRedis)(nil)
 
 func NewCacheStoreRedis(
 	Addr string,
 	Password string,
 	DB int,
 	opts ...comby.CacheStoreOption,
-) comby.CacheStore {
+) CacheStoreRedis {
+	return NewCacheStoreRedisWithOptions(&redis.Options{
+		Addr:     Addr,
+		Password: Password,
+		DB:       DB,
+	}, OptionWithCacheStoreOptions(opts...))
+}
+
+// NewCacheStoreRedisWithOptions creates a cache store using the given redis
+// client options and redis specific store options.
+func NewCacheStoreRedisWithOptions(
+	redisOptions *redis.Options,
+	opts ...Option,
+) CacheStoreRedis {
+	csr, err := newCacheStoreRedis(opts...)
+	if err != nil {
+		return nil
+	}
+	csr.redisOptions = redisOptions
+	return csr
+}
+
+// newCacheStoreRedis creates the store with the given store options applied
+func newCacheStoreRedis(opts ...Option) (*cacheStoreRedis, error) {
 	csr := &cacheStoreRedis{
 		options: comby.CacheStoreOptions{},
-		redisOptions: &redis.Options{
-			Addr:     Addr,
-			Password: Password,
-			DB:       DB,
+		storeOptions: Options{
+			ScanCount:           defaultScanCount,
+			Codec:               NewJSONCodec(),
+			LoadTimeout:         defaultLoadTimeout,
+			MaxDecompressedSize: defaultMaxDecompressedSize,
 		},
 	}
 	for _, opt := range opts {
+		if _, err := opt(&csr.storeOptions); err != nil {
+			return nil, err
+		}
+	}
+	for _, opt := range csr.storeOptions.CacheStoreOptions {
 		if _, err := opt(&csr.options); err != nil {
-			return nil
+			return nil, err
 		}
 	}
-	return csr
+	return csr, nil
 }
 
 // fullfilling CacheStore interface
@@ -49,7 +203,104 @@ func (csr *cacheStoreRedis) Init(ctx context.Context, opts ...comby.CacheStoreOp
 			return err
 		}
 	}
-	csr.redisClient = redis.NewClient(csr.redisOptions)
+	if keyring := csr.storeOptions.Keyring; keyring != nil && keyring.Keys[keyring.CurrentKeyID] == nil {
+		return fmt.Errorf("'%s' failed - keyring has no current key", csr.String())
+	}
+	if csr.storeOptions.Quota != nil {
+		if csr.storeOptions.TenantResolver == nil {
+			return fmt.Errorf("'%s' failed - tenant quotas require the tenant index", csr.String())
+		}
+		if csr.clusterOptions != nil {
+			return fmt.Errorf("'%s' failed - tenant quotas are not supported on Redis Cluster", csr.String())
+		}
+	}
+	if csr.storeOptions.Tags && csr.clusterOptions != nil {
+		return fmt.Errorf("'%s' failed - tags are not supported on Redis Cluster", csr.String())
+	}
+	if csr.storeOptions.ClientTracking {
+		if csr.storeOptions.NearCache == nil {
+			return fmt.Errorf("'%s' failed - client tracking requires the near cache", csr.String())
+		}
+		if csr.clusterOptions != nil {
+			return fmt.Errorf("'%s' failed - client tracking is not supported on Redis Cluster", csr.String())
+		}
+	}
+	tlsOptions := csr.storeOptions.TLS
+	switch {
+	case csr.clusterOptions != nil:
+		clusterOptions := *csr.clusterOptions
+		if csr.storeOptions.RetryPolicy != nil {
+			clusterOptions.MaxRetries = -1
+		}
+		if csr.storeOptions.ReadFromReplicas {
+			clusterOptions.ReadOnly = true
+		}
+		if tlsOptions != nil {
+			clusterOptions.TLSConfig = tlsOptions.tlsConfig(clusterOptions.TLSConfig)
+		}
+		csr.redisClient = redis.NewClusterClient(&clusterOptions)
+		csr.readClient = csr.redisClient
+	case csr.failoverOptions != nil:
+		failoverOptions := *csr.failoverOptions
+		if csr.storeOptions.RetryPolicy != nil {
+			failoverOptions.MaxRetries = -1
+		}
+		if tlsOptions != nil {
+			failoverOptions.TLSConfig = tlsOptions.tlsConfig(failoverOptions.TLSConfig)
+		}
+		csr.redisClient = redis.NewFailoverClient(&failoverOptions)
+		csr.readClient = csr.redisClient
+		if csr.storeOptions.ReadFromReplicas {
+			replicaOptions := failoverOptions
+			replicaOptions.ReplicaOnly = true
+			csr.readClient = redis.NewFailoverClient(&replicaOptions)
+		}
+	default:
+		if csr.storeOptions.ReadFromReplicas {
+			return fmt.Errorf("'%s' failed - reading from replicas requires Redis Cluster or Sentinel", csr.String())
+		}
+		redisOptions := *csr.redisOptions
+		if csr.storeOptions.RetryPolicy != nil {
+			redisOptions.MaxRetries = -1
+		}
+		if tlsOptions != nil {
+			redisOptions.TLSConfig = tlsOptions.tlsConfig(redisOptions.TLSConfig)
+		}
+		csr.redisClient = redis.NewClient(&redisOptions)
+		csr.readClient = csr.redisClient
+	}
+	// classify errors and retry failed calls, the circuit breaker is called
+	// for every attempt
+	retry := &retryHook{policy: csr.storeOptions.RetryPolicy}
+	csr.redisClient.AddHook(retry)
+	if csr.readClient != csr.redisClient {
+		csr.readClient.AddHook(retry)
+	}
+	if opts := csr.storeOptions.CircuitBreaker; opts != nil {
+		var onClose func()
+		if csr.storeOptions.Fallback != nil {
+			onClose = csr.reconcile
+		}
+		csr.breaker = newCircuitBreaker(opts, onClose)
+		csr.redisClient.AddHook(csr.breaker)
+		if csr.readClient != csr.redisClient {
+			csr.readClient.AddHook(csr.breaker)
+		}
+	}
+	if csr.storeOptions.ConnectAttempts > 0 {
+		if err := csr.connect(ctx); err != nil {
+			csr.Close(ctx)
+			csr.redisClient, csr.readClient = nil, nil
+			return err
+		}
+	}
+	if csr.storeOptions.NearCache != nil {
+		if err := csr.startNearCache(ctx); err != nil {
+			csr.Close(ctx)
+			csr.redisClient, csr.readClient = nil, nil
+			return fmt.Errorf("'%s' failed - failed to start near cache: %w", csr.String(), err)
+		}
+	}
 	return nil
 }
 
@@ -60,7 +311,36 @@ func (csr *cacheStoreRedis) Get(ctx context.Context, opts ...comby.CacheStoreGet
 			return nil, err
 		}
 	}
-	value, err := csr.redisClient.Get(ctx, getOpts.Key).Result()
+	entry, err := csr.GetEntry(ctx, getOpts.Key)
+	if csr.useFallback(err) {
+		return csr.storeOptions.Fallback.Get(ctx, opts...)
+	}
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return entry.CacheModel, nil
+}
+
+// get returns the entry stored under redisKey as cache entry of key
+func (csr *cacheStoreRedis) get(ctx context.Context, redisKey, key string) (*comby.CacheModel, error) {
+	entry, err := csr.getEntry(ctx, redisKey, key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return entry.CacheModel, nil
+}
+
+// getEntry returns the entry stored under redisKey as entry of key
+func (csr *cacheStoreRedis) getEntry(ctx context.Context, redisKey, key string) (*Entry, error) {
+	// fetch value and remaining time to live within a single round trip
+	pipe := csr.readClient.Pipeline()
+	getCmd := pipe.Get(ctx, redisKey)
+	ttlCmd := pipe.PTTL(ctx, redisKey)
+	now := time.Now()
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	value, err := getCmd.Result()
 	switch {
 	case err == redis.Nil: // key does not exist
 		return nil, nil
@@ -68,24 +348,25 @@ func (csr *cacheStoreRedis) Get(ctx context.Context, opts ...comby.CacheStoreGet
 		return nil, err
 	}
 
-	valueToReturn := any(value)
-
-	// decrypt value if crypto service is provided
-	if csr.options.CryptoService != nil {
-		// value is stored as string in Redis, convert to []byte for decryption
-		if strValue, ok := valueToReturn.(string); ok {
-			decryptedValue, err := csr.decryptValue([]byte(strValue))
-			if err != nil {
-				return nil, err
-			}
-			valueToReturn = decryptedValue
-		}
+	// value is stored as string in Redis, convert to []byte for decoding
+	valueToReturn, header, err := csr.decodeEntry(key, []byte(value))
+	if err != nil {
+		return nil, err
 	}
 
-	return &comby.CacheModel{
-		Key:   getOpts.Key,
-		Value: valueToReturn,
-	}, nil
+	entry := &Entry{
+		CacheModel: &comby.CacheModel{
+			Key:       key,
+			Value:     valueToReturn,
+			ExpiredAt: expiredAt(now, ttlCmd.Val()),
+		},
+	}
+	if header != nil {
+		entry.StaleAt = header.staleAt()
+		entry.Stale = now.UnixNano() >= entry.StaleAt
+		entry.header = header
+	}
+	return entry, nil
 }
 
 func (csr *cacheStoreRedis) Set(ctx context.Context, opts ...comby.CacheStoreSetOption) error {
@@ -98,18 +379,66 @@ func (csr *cacheStoreRedis) Set(ctx context.Context, opts ...comby.CacheStoreSet
 		}
 	}
 
-	valueToStore := setOpts.Value
+	err := csr.set(ctx, setOpts.Key, setOpts.Value, setOpts.Expiration, csr.storeOptions.SoftTTL)
+	if csr.useFallback(err) {
+		csr.markFallbackWrite(setOpts.Key)
+		return csr.storeOptions.Fallback.Set(ctx, opts...)
+	}
+	return err
+}
 
-	// encrypt value if crypto service is provided
-	if csr.options.CryptoService != nil {
-		encryptedValue, err := csr.encryptValue(setOpts.Value)
-		if err != nil {
+func (csr *cacheStoreRedis) SetWithOptions(ctx context.Context, opts ...SetOption) error {
+	setOpts := SetOptions{
+		Expiration: 60 * time.Second,
+		SoftTTL:    csr.storeOptions.SoftTTL,
+	}
+	for _, opt := range opts {
+		if _, err := opt(&setOpts); err != nil {
 			return err
 		}
-		valueToStore = encryptedValue
 	}
+	return csr.setWithOptions(ctx, &setOpts)
+}
 
-	return csr.redisClient.Set(ctx, setOpts.Key, valueToStore, setOpts.Expiration).Err()
+// setWithOptions writes an entry, tagged if tags are given
+func (csr *cacheStoreRedis) setWithOptions(ctx context.Context, setOpts *SetOptions) error {
+	if len(setOpts.Tags) > 0 {
+		return csr.setTagged(ctx, setOpts)
+	}
+	return csr.set(ctx, setOpts.Key, setOpts.Value, setOpts.Expiration, setOpts.SoftTTL)
+}
+
+func (csr *cacheStoreRedis) set(ctx context.Context, key string, value any, expiration, softTTL time.Duration) error {
+	valueToStore, err := csr.encodeEntry(key, value, expiration, softTTL)
+	if err != nil {
+		return err
+	}
+	// tags of a previous write no longer apply
+	if err := csr.untag(ctx, key); err != nil {
+		return err
+	}
+
+	pipe := csr.redisClient.Pipeline()
+	setCmd := csr.queueSet(ctx, pipe, key, valueToStore, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return csr.quotaError(key, err)
+	}
+	if err := setCmd.Err(); err != nil {
+		return err
+	}
+	csr.invalidate(ctx, append(csr.evictedKeys(setCmd), key)...)
+	return nil
+}
+
+// queueSet queues writing an encoded entry without tags, maintaining tenant
+// index and enforcing the tenant quota if enabled
+func (csr *cacheStoreRedis) queueSet(ctx context.Context, pipe redis.Pipeliner, key string, value []byte, expiration time.Duration) redis.Cmder {
+	if tenantUuid, ok := csr.quotaTenant(key); ok {
+		return csr.queueQuotaSet(ctx, pipe, tenantUuid, key, value, expiration)
+	}
+	cmd := pipe.Set(ctx, csr.key(key), value, expiration)
+	csr.indexTenant(ctx, pipe, key, expiration)
+	return cmd
 }
 
 func (csr *cacheStoreRedis) List(ctx context.Context, opts ...comby.CacheStoreListOption) ([]*comby.CacheModel, int64, error) {
@@ -119,51 +448,262 @@ func (csr *cacheStoreRedis) List(ctx context.Context, opts ...comby.CacheStoreLi
 			return nil, 0, err
 		}
 	}
-	var items []*comby.CacheModel
-	// TODO: naive implementation, should be replaced with SCAN
-	keys, err := csr.redisClient.Keys(ctx, "*").Result()
-	switch {
-	case err == redis.Nil: // key does not exist
-		return nil, 0, nil
-	case err != nil: // failed to get
-		return nil, 0, err
+	items, total, err := csr.ListWithOptions(ctx, ListOptionWithTenantUuid(listOpts.TenantUuid))
+	if csr.useFallback(err) {
+		return csr.storeOptions.Fallback.List(ctx, opts...)
 	}
+	return items, total, err
+}
 
-	for _, key := range keys {
-		value, err := csr.redisClient.Get(ctx, key).Result()
-		if err != nil {
+func (csr *cacheStoreRedis) ListWithOptions(ctx context.Context, opts ...ListOption) ([]*comby.CacheModel, int64, error) {
+	listOpts := ListOptions{
+		OrderBy:   ListOrderByKey,
+		Ascending: true,
+	}
+	for _, opt := range opts {
+		if _, err := opt(&listOpts); err != nil {
 			return nil, 0, err
 		}
-		valid := len(listOpts.TenantUuid) == 0
-		if !valid {
+	}
+
+	// collect matching keys only, values are fetched for the requested page
+	var keys []string
+	if csr.storeOptions.TenantResolver != nil && len(listOpts.TenantUuid) > 0 {
+		var err error
+		if keys, err = csr.tenantKeys(ctx, listOpts.TenantUuid); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		match := escapePattern(csr.key("")) + "*"
+		if len(listOpts.TenantUuid) > 0 {
 			// convention: prefix of key is the tenantUuid "%s-%s"
-			valid = strings.HasPrefix(key, listOpts.TenantUuid)
-		}
-		if valid {
-			valueToReturn := any(value)
-
-			// decrypt value if crypto service is provided
-			if csr.options.CryptoService != nil {
-				// value is stored as string in Redis, convert to []byte for decryption
-				if strValue, ok := valueToReturn.(string); ok {
-					decryptedValue, err := csr.decryptValue([]byte(strValue))
-					if err != nil {
-						// skip items that fail to decrypt
-						continue
-					}
-					valueToReturn = decryptedValue
+			match = csr.tenantPattern(listOpts.TenantUuid)
+		}
+		if err := csr.scan(ctx, csr.readClient, match, func(batch []string) error {
+			for _, key := range batch {
+				if !csr.isInternal(key) {
+					keys = append(keys, key)
 				}
 			}
+			return nil
+		}); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// order keys
+	switch listOpts.OrderBy {
+	case ListOrderByKey:
+		sort.Strings(keys)
+	case ListOrderByTTL:
+		var err error
+		if keys, err = csr.sortByTTL(ctx, keys); err != nil {
+			return nil, 0, err
+		}
+	}
+	if !listOpts.Ascending {
+		slices.Reverse(keys)
+	}
+	var total int64 = int64(len(keys))
+
+	// apply offset and limit
+	if listOpts.Offset >= total {
+		return nil, total, nil
+	}
+	keys = keys[listOpts.Offset:]
+	if listOpts.Limit > 0 && listOpts.Limit < int64(len(keys)) {
+		keys = keys[:listOpts.Limit]
+	}
+
+	items, err := csr.fetch(ctx, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// fetch returns the cache entries of the given keys, fetching the values in
+// batches of ScanCount keys per round trip. Keys which no longer exist or
+// fail to decode are skipped.
+func (csr *cacheStoreRedis) fetch(ctx context.Context, keys []string) ([]*comby.CacheModel, error) {
+	var items []*comby.CacheModel
+	for _, batch := range batches(keys, csr.storeOptions.ScanCount) {
+		pipe := csr.readClient.Pipeline()
+		cmds := make([]*redis.StringCmd, len(batch))
+		ttlCmds := make([]*redis.DurationCmd, len(batch))
+		for i, key := range batch {
+			cmds[i] = pipe.Get(ctx, key)
+			ttlCmds[i] = pipe.PTTL(ctx, key)
+		}
+		now := time.Now()
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			value, err := cmd.Result()
+			switch {
+			case err == redis.Nil: // key expired or deleted in the meantime
+				continue
+			case err != nil: // failed to get
+				return nil, err
+			}
+
+			// value is stored as string in Redis, convert to []byte for decoding
+			valueToReturn, err := csr.decodeValue(csr.unkey(batch[i]), []byte(value))
+			if err != nil {
+				// skip items that fail to decrypt or decode
+				continue
+			}
 
 			items = append(items, &comby.CacheModel{
-				Key:       key,
+				Key:       csr.unkey(batch[i]),
 				Value:     valueToReturn,
-				ExpiredAt: 0,
+				ExpiredAt: expiredAt(now, ttlCmds[i].Val()),
 			})
 		}
 	}
-	var total int64 = int64(len(items))
-	return items, total, nil
+	return items, nil
+}
+
+// sortByTTL orders the given keys by their remaining time to live. Keys
+// without expiration are considered to live forever and keys which no
+// longer exist are removed.
+func (csr *cacheStoreRedis) sortByTTL(ctx context.Context, keys []string) ([]string, error) {
+	ttls := make(map[string]time.Duration, len(keys))
+	for _, batch := range batches(keys, csr.storeOptions.ScanCount) {
+		pipe := csr.readClient.Pipeline()
+		cmds := make([]*redis.DurationCmd, len(batch))
+		for i, key := range batch {
+			cmds[i] = pipe.PTTL(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			ttl := cmd.Val()
+			switch {
+			case ttl == -2: // key does not exist
+				continue
+			case ttl < 0: // key has no expiration
+				ttl = time.Duration(math.MaxInt64)
+			}
+			ttls[batch[i]] = ttl
+		}
+	}
+	sorted := make([]string, 0, len(ttls))
+	for _, key := range keys {
+		if _, ok := ttls[key]; ok {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if ttls[sorted[i]] == ttls[sorted[j]] {
+			return sorted[i] < sorted[j]
+		}
+		return ttls[sorted[i]] < ttls[sorted[j]]
+	})
+	return sorted, nil
+}
+
+// expiredAt converts the remaining time to live reported by PTTL into an
+// absolute expiration time in unix nanoseconds. Keys without expiration
+// return 0.
+func expiredAt(now time.Time, ttl time.Duration) int64 {
+	if ttl < 0 {
+		return 0
+	}
+	return now.Add(ttl).UnixNano()
+}
+
+// batches splits keys into chunks of at most size keys
+func batches(keys []string, size int64) [][]string {
+	var chunks [][]string
+	for int64(len(keys)) > size {
+		chunks = append(chunks, keys[:size])
+		keys = keys[size:]
+	}
+	if len(keys) > 0 {
+		chunks = append(chunks, keys)
+	}
+	return chunks
+}
+
+// scan iterates over all keys matching the given pattern using SCAN and
+// calls fn once per returned batch. Keys reported more than once by SCAN
+// (which may happen while the keyspace is rehashed) are passed only once.
+// On Redis Cluster all masters are scanned, fn is never called concurrently.
+func (csr *cacheStoreRedis) scan(ctx context.Context, client redis.UniversalClient, match string, fn func(keys []string) error) error {
+	var mu sync.Mutex
+	return csr.forEachShard(ctx, client, func(ctx context.Context, client *redis.Client) error {
+		seen := make(map[string]struct{})
+		var cursor uint64
+		for {
+			keys, nextCursor, err := client.Scan(ctx, cursor, match, csr.storeOptions.ScanCount).Result()
+			if err != nil {
+				return err
+			}
+			batch := keys[:0]
+			for _, key := range keys {
+				if _, ok := seen[key]; ok {
+					continue
+				}
+				seen[key] = struct{}{}
+				batch = append(batch, key)
+			}
+			if len(batch) > 0 {
+				mu.Lock()
+				err := fn(batch)
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			if nextCursor == 0 {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	})
+}
+
+// unlink removes the given keys within a single round trip, entries are
+// removed from their tags as well. Keys are unlinked one by one, as keys of
+// a batch may belong to different hash slots on Redis Cluster.
+func (csr *cacheStoreRedis) unlink(ctx context.Context, keys []string) (int64, error) {
+	pipe := csr.redisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	entryKeys := make([]string, 0, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Unlink(ctx, key)
+		if !csr.isInternal(key) {
+			entryKeys = append(entryKeys, csr.unkey(key))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	if err := csr.untag(ctx, entryKeys...); err != nil {
+		return 0, err
+	}
+	var removed int64
+	for _, cmd := range cmds {
+		removed += cmd.Val()
+	}
+	return removed, nil
+}
+
+// escapePattern escapes all glob-style characters so that the given string
+// is matched literally by SCAN MATCH.
+func escapePattern(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
 }
 
 func (csr *cacheStoreRedis) Delete(ctx context.Context, opts ...comby.CacheStoreDeleteOption) error {
@@ -174,8 +714,19 @@ func (csr *cacheStoreRedis) Delete(ctx context.Context, opts ...comby.CacheStore
 		}
 	}
 	if csr.redisClient != nil {
-		ctx := context.Background()
-		csr.redisClient.Del(ctx, deleteOpts.Key)
+		pipe := csr.redisClient.Pipeline()
+		pipe.Del(ctx, csr.key(deleteOpts.Key))
+		csr.unindexTenant(ctx, pipe, deleteOpts.Key)
+		_, err := pipe.Exec(ctx)
+		if err == nil {
+			err = csr.untag(ctx, deleteOpts.Key)
+		}
+		csr.invalidate(ctx, deleteOpts.Key)
+		if csr.useFallback(err) {
+			csr.markFallbackWrite(deleteOpts.Key)
+			return csr.storeOptions.Fallback.Delete(ctx, opts...)
+		}
+		return err
 	}
 	return nil
 }
@@ -183,12 +734,26 @@ func (csr *cacheStoreRedis) Delete(ctx context.Context, opts ...comby.CacheStore
 func (csr *cacheStoreRedis) Total(ctx context.Context) int64 {
 	total := int64(0)
 	if csr.redisClient != nil {
-		return csr.redisClient.DBSize(ctx).Val()
+		var err error
+		if total, err = csr.count(ctx); csr.useFallback(err) {
+			return csr.storeOptions.Fallback.Total(ctx)
+		}
 	}
 	return total
 }
 
 func (csr *cacheStoreRedis) Close(ctx context.Context) error {
+	// wait for background refreshes and reconciliations using the clients
+	csr.refreshWG.Wait()
+	csr.fallbackWG.Wait()
+	if err := csr.stopNearCache(); err != nil {
+		return err
+	}
+	if csr.readClient != nil && csr.readClient != csr.redisClient {
+		if err := csr.readClient.Close(); err != nil {
+			return err
+		}
+	}
 	if csr.redisClient != nil {
 		return csr.redisClient.Close()
 	}
@@ -200,63 +765,322 @@ func (csr *cacheStoreRedis) Options() comby.CacheStoreOptions {
 }
 
 func (csr *cacheStoreRedis) String() string {
-	return fmt.Sprintf("redis://%s:***@%s/%q", csr.redisOptions.Username, csr.redisOptions.Addr, csr.redisOptions.DB)
+	switch {
+	case csr.clusterOptions != nil:
+		return fmt.Sprintf("redis-cluster://%s:***@%s", csr.clusterOptions.Username, strings.Join(csr.clusterOptions.Addrs, ","))
+	case csr.failoverOptions != nil:
+		return fmt.Sprintf("redis-sentinel://%s:***@%s/%d?master=%s", csr.failoverOptions.Username, strings.Join(csr.failoverOptions.SentinelAddrs, ","), csr.failoverOptions.DB, csr.failoverOptions.MasterName)
+	case csr.redisOptions.Network == "unix":
+		return fmt.Sprintf("unix://%s:***@%s?db=%d", csr.redisOptions.Username, csr.redisOptions.Addr, csr.redisOptions.DB)
+	case csr.redisOptions.TLSConfig != nil || csr.storeOptions.TLS != nil:
+		return fmt.Sprintf("rediss://%s:***@%s/%d", csr.redisOptions.Username, csr.redisOptions.Addr, csr.redisOptions.DB)
+	default:
+		return fmt.Sprintf("redis://%s:***@%s/%d", csr.redisOptions.Username, csr.redisOptions.Addr, csr.redisOptions.DB)
+	}
 }
 
 func (csr *cacheStoreRedis) Info(ctx context.Context) (*comby.CacheStoreInfoModel, error) {
 	// total records
 	dbTotal := int64(0)
 	if csr.redisClient != nil {
-		dbTotal = csr.redisClient.DBSize(ctx).Val()
+		var err error
+		if dbTotal, err = csr.count(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	connectionInfo := csr.String()
+	if csr.failoverOptions != nil {
+		// report the node currently serving as master
+		masterAddr, err := csr.masterAddr(ctx)
+		if err != nil {
+			return nil, err
+		}
+		connectionInfo = fmt.Sprintf("%s (master: %s)", connectionInfo, masterAddr)
 	}
 
 	return &comby.CacheStoreInfoModel{
 		StoreType:      "redis",
 		NumItems:       dbTotal,
-		ConnectionInfo: fmt.Sprintf("redis://%s:***@%s/%q", csr.redisOptions.Username, csr.redisOptions.Addr, csr.redisOptions.DB),
+		ConnectionInfo: connectionInfo,
 	}, nil
 }
 
 func (csr *cacheStoreRedis) Reset(ctx context.Context) error {
-	return csr.redisClient.FlushDB(ctx).Err()
+	err := csr.reset(ctx)
+	if csr.useFallback(err) {
+		csr.markFallbackReset()
+		return csr.storeOptions.Fallback.Reset(ctx)
+	}
+	return err
 }
 
-func (csr *cacheStoreRedis) encryptValue(value any) ([]byte, error) {
-	if csr.options.CryptoService == nil {
-		return nil, fmt.Errorf("'%s' failed - crypto service is nil", csr.String())
+func (csr *cacheStoreRedis) reset(ctx context.Context) error {
+	defer csr.invalidateAll(ctx)
+	if len(csr.storeOptions.Namespace) == 0 {
+		return csr.forEachShard(ctx, csr.redisClient, func(ctx context.Context, client *redis.Client) error {
+			return client.FlushDB(ctx).Err()
+		})
+	}
+	// remove keys of this namespace only
+	return csr.scan(ctx, csr.redisClient, escapePattern(csr.storeOptions.Namespace)+"*", func(keys []string) error {
+		_, err := csr.unlink(ctx, keys)
+		return err
+	})
+}
+
+func (csr *cacheStoreRedis) DeleteByTenant(ctx context.Context, tenantUuid string) (int64, error) {
+	if len(tenantUuid) == 0 {
+		return 0, fmt.Errorf("'%s' failed - tenantUuid is empty", csr.String())
+	}
+
+	// collect keys first, unlinking while scanning may skip keys
+	var entryKeys, internalKeys []string
+	if csr.storeOptions.TenantResolver != nil {
+		var err error
+		if entryKeys, err = csr.tenantKeys(ctx, tenantUuid); err != nil {
+			return 0, err
+		}
+		// internal keys of the tenant's entries, e.g. stale copies and locks
+		for _, key := range entryKeys {
+			for _, kind := range []string{"stale", "lock", "refresh"} {
+				internalKeys = append(internalKeys, csr.internalKey(kind, csr.unkey(key)))
+			}
+		}
+	} else {
+		// convention: prefix of key is the tenantUuid "%s-%s"
+		matches := []string{
+			csr.tenantPattern(tenantUuid),
+			// internal keys of the tenant's entries, e.g. stale copies and locks
+			escapePattern(csr.storeOptions.Namespace+internalKeyPrefix) + "*:" + escapePattern(tenantUuid+"-") + "*",
+		}
+		for _, match := range matches {
+			if err := csr.scan(ctx, csr.redisClient, match, func(keys []string) error {
+				for _, key := range keys {
+					if csr.isInternal(key) {
+						internalKeys = append(internalKeys, key)
+					} else {
+						entryKeys = append(entryKeys, key)
+					}
+				}
+				return nil
+			}); err != nil {
+				return 0, fmt.Errorf("'%s' failed - failed to scan tenant: %w", csr.String(), err)
+			}
+		}
+	}
+
+	// tenant index and usage, if enabled
+	internalKeys = append(internalKeys, csr.quotaKeys(tenantUuid)...)
+
+	var removed int64
+	defer csr.invalidateAll(ctx)
+	for _, batch := range batches(entryKeys, csr.storeOptions.ScanCount) {
+		n, err := csr.unlink(ctx, batch)
+		if err != nil {
+			return removed, fmt.Errorf("'%s' failed - failed to delete tenant: %w", csr.String(), err)
+		}
+		removed += n
+		if csr.storeOptions.TenantResolver != nil {
+			members := make([]any, len(batch))
+			for i, key := range batch {
+				members[i] = key
+			}
+			if err := csr.redisClient.ZRem(ctx, csr.unresolvedIndexKey(), members...).Err(); err != nil {
+				return removed, fmt.Errorf("'%s' failed - failed to update tenant index: %w", csr.String(), err)
+			}
+		}
 	}
-	// serialize value to JSON
-	valueBytes, err := json.Marshal(value)
+	for _, batch := range batches(internalKeys, csr.storeOptions.ScanCount) {
+		if _, err := csr.unlink(ctx, batch); err != nil {
+			return removed, fmt.Errorf("'%s' failed - failed to delete tenant: %w", csr.String(), err)
+		}
+	}
+	return removed, nil
+}
+
+// count returns the number of entries of this store. Without a namespace
+// the store owns the whole database and the internal keys found using SCAN
+// are subtracted from its size, otherwise the entries of the namespace are
+// counted using SCAN.
+func (csr *cacheStoreRedis) count(ctx context.Context) (int64, error) {
+	var total int64
+	if len(csr.storeOptions.Namespace) == 0 {
+		if err := csr.forEachShard(ctx, csr.redisClient, func(ctx context.Context, client *redis.Client) error {
+			size, err := client.DBSize(ctx).Result()
+			atomic.AddInt64(&total, size)
+			return err
+		}); err != nil {
+			return total, err
+		}
+		err := csr.scan(ctx, csr.redisClient, escapePattern(internalKeyPrefix)+"*", func(keys []string) error {
+			total -= int64(len(keys))
+			return nil
+		})
+		// keys may be written while counting
+		return max(total, 0), err
+	}
+	err := csr.scan(ctx, csr.redisClient, escapePattern(csr.storeOptions.Namespace)+"*", func(keys []string) error {
+		for _, key := range keys {
+			if !csr.isInternal(key) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+// key returns the redis key of the given cache key
+func (csr *cacheStoreRedis) key(key string) string {
+	return csr.storeOptions.Namespace + key
+}
+
+// unkey returns the cache key of the given redis key
+func (csr *cacheStoreRedis) unkey(key string) string {
+	return strings.TrimPrefix(key, csr.storeOptions.Namespace)
+}
+
+// internalKey returns the redis key of data maintained by the store itself,
+// e.g. locks. Internal keys are not returned by List.
+func (csr *cacheStoreRedis) internalKey(kind, key string) string {
+	return csr.storeOptions.Namespace + internalKeyPrefix + kind + ":" + key
+}
+
+// isInternal reports whether the given redis key is an internal key
+func (csr *cacheStoreRedis) isInternal(key string) bool {
+	return strings.HasPrefix(key, csr.storeOptions.Namespace+internalKeyPrefix)
+}
+
+// encodeEntry serializes the value using the configured codec, prefixes a
+// soft TTL header if softTTL is set and shorter than ttl, compresses it if
+// configured and encrypts the result bound to key if crypto service is
+// provided
+func (csr *cacheStoreRedis) encodeEntry(key string, value any, ttl, softTTL time.Duration) ([]byte, error) {
+	valueBytes, err := csr.storeOptions.Codec.Encode(value)
 	if err != nil {
-		return nil, fmt.Errorf("'%s' failed - failed to marshal value: %w", csr.String(), err)
+		return nil, classified(ErrCodec, fmt.Errorf("'%s' failed - failed to encode value: %w", csr.String(), err))
+	}
+	if softTTL > 0 && (ttl <= 0 || softTTL < ttl) {
+		header := &softTTLHeader{StoredAt: time.Now().UnixNano(), TTL: ttl, SoftTTL: softTTL}
+		valueBytes = header.encode(valueBytes)
+	}
+	valueBytes, err = csr.compress(valueBytes)
+	if err != nil {
+		return nil, classified(ErrCodec, err)
+	}
+	if csr.encrypted(key) {
+		return csr.encryptValue(key, valueBytes)
+	}
+	return valueBytes, nil
+}
+
+// decodeValue reverses encodeEntry. Values not written by the codec are
+// returned as written by previous versions: raw strings if unencrypted,
+// generic JSON values if encrypted.
+func (csr *cacheStoreRedis) decodeValue(key string, data []byte) (any, error) {
+	value, _, err := csr.decodeEntry(key, data)
+	return value, err
+}
+
+// decodeEntry reverses encodeEntry, the soft TTL header is nil if the value
+// was stored without
+func (csr *cacheStoreRedis) decodeEntry(key string, data []byte) (any, *softTTLHeader, error) {
+	legacyValue := func(data []byte) (any, error) {
+		return string(data), nil
+	}
+	if csr.encrypted(key) {
+		decryptedBytes, err := csr.decryptValue(key, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = decryptedBytes
+		legacyValue = func(data []byte) (any, error) {
+			var value any
+			if err := json.Unmarshal(data, &value); err != nil {
+				return nil, classified(ErrCodec, fmt.Errorf("'%s' failed - failed to unmarshal value: %w", csr.String(), err))
+			}
+			return value, nil
+		}
+	}
+	data, err := csr.decompress(data)
+	if err != nil {
+		return nil, nil, classified(ErrCodec, err)
+	}
+	data, header := decodeSoftTTLHeader(data)
+	value, err := csr.storeOptions.Codec.Decode(data)
+	switch {
+	case errors.Is(err, ErrNotEncoded):
+		value, err = legacyValue(data)
+		return value, header, err
+	case err != nil:
+		return nil, nil, classified(ErrCodec, fmt.Errorf("'%s' failed - failed to decode value: %w", csr.String(), err))
+	}
+	return value, header, nil
+}
+
+// encryptValue encrypts valueBytes bound to key, so the ciphertext is
+// rejected when read from another key
+func (csr *cacheStoreRedis) encryptValue(key string, valueBytes []byte) ([]byte, error) {
+	keyID, cipher, err := csr.encryptionKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to get encryption key: %w", csr.String(), err)
+	}
+	if cipher == nil {
+		return nil, fmt.Errorf("'%s' failed - crypto service is nil", csr.String())
 	}
 	if len(valueBytes) < 1 {
 		return nil, fmt.Errorf("'%s' failed - value is empty", csr.String())
 	}
-	// encrypt serialized value
-	encryptedValue, err := csr.options.CryptoService.Encrypt(valueBytes)
+	// encrypt serialized value, in an envelope if the key has an ID
+	boundBytes := bindKey(key, valueBytes)
+	var encryptedValue []byte
+	if len(keyID) > 0 {
+		encryptedValue, err = sealEnvelope(keyID, cipher, boundBytes)
+	} else {
+		encryptedValue, err = cipher.Encrypt(boundBytes)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("'%s' failed - failed to encrypt value: %w", csr.String(), err)
 	}
 	return encryptedValue, nil
 }
 
-func (csr *cacheStoreRedis) decryptValue(encryptedValue []byte) (any, error) {
-	if csr.options.CryptoService == nil {
-		return nil, fmt.Errorf("'%s' failed - crypto service is nil", csr.String())
+// decryptValue decrypts a value read from key, errors are classified as
+// ErrDecrypt
+func (csr *cacheStoreRedis) decryptValue(key string, encryptedValue []byte) ([]byte, error) {
+	if !csr.encrypted(key) {
+		return nil, classified(ErrDecrypt, fmt.Errorf("'%s' failed - crypto service is nil", csr.String()))
+	}
+	decryptedBytes, err := csr.decryptRaw(encryptedValue)
+	if err == nil {
+		decryptedBytes, err = csr.verifyKey(key, decryptedBytes)
 	}
+	if err != nil {
+		return nil, classified(ErrDecrypt, err)
+	}
+	return decryptedBytes, nil
+}
+
+// decryptRaw decrypts envelopes using the key of their key ID. Values
+// without envelope were encrypted by the crypto service directly.
+func (csr *cacheStoreRedis) decryptRaw(encryptedValue []byte) ([]byte, error) {
 	if len(encryptedValue) < 1 {
 		return nil, fmt.Errorf("'%s' failed - encrypted value is empty", csr.String())
 	}
-	// decrypt value
-	decryptedBytes, err := csr.options.CryptoService.Decrypt(encryptedValue)
-	if err != nil {
+	decryptedBytes, isEnvelope, err := csr.openEnvelope(encryptedValue)
+	switch {
+	case isEnvelope && err == nil:
+		return decryptedBytes, nil
+	case isEnvelope && csr.options.CryptoService == nil:
 		return nil, fmt.Errorf("'%s' failed - failed to decrypt value: %w", csr.String(), err)
+	case csr.options.CryptoService == nil:
+		return nil, fmt.Errorf("'%s' failed - value without key id and no crypto service", csr.String())
 	}
-	// deserialize JSON to any
-	var value any
-	if err := json.Unmarshal(decryptedBytes, &value); err != nil {
-		return nil, fmt.Errorf("'%s' failed - failed to unmarshal value: %w", csr.String(), err)
+	// decrypt value, legacy ciphertext may look like an envelope by chance
+	decryptedBytes, err = csr.options.CryptoService.Decrypt(encryptedValue)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to decrypt value: %w", csr.String(), err)
 	}
-	return value, nil
+	return decryptedBytes, nil
 }
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConnectError is returned by Init if the connection could not be verified
+type ConnectError struct {
+	// Store is the redacted connection string of the store
+	Store string
+	// Attempts is the number of failed PINGs
+	Attempts int
+	// Err is the error of the last attempt
+	Err error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("'%s' failed - failed to connect after %d attempt(s): %v", e.Store, e.Attempts, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// HealthStatus is the result of HealthCheck
+type HealthStatus struct {
+	// Latency is the round trip time of PING
+	Latency time.Duration
+	// ServerVersion is the version of the Redis server, e.g. "7.2.4"
+	ServerVersion string
+	// Role is the replication role of the server, e.g. "master" or "slave"
+	Role string
+}
+
+func (csr *cacheStoreRedis) HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	if csr.redisClient == nil {
+		return nil, fmt.Errorf("'%s' failed - store not initialized", csr.String())
+	}
+
+	// latency
+	start := time.Now()
+	if err := csr.redisClient.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to ping: %w", csr.String(), err)
+	}
+	latency := time.Since(start)
+
+	// server version and role
+	pipe := csr.redisClient.Pipeline()
+	serverCmd := pipe.Info(ctx, "server")
+	replicationCmd := pipe.Info(ctx, "replication")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to get info: %w", csr.String(), err)
+	}
+
+	return &HealthStatus{
+		Latency:       latency,
+		ServerVersion: parseInfo(serverCmd.Val())["redis_version"],
+		Role:          parseInfo(replicationCmd.Val())["role"],
+	}, nil
+}
+
+// connect verifies the connection using PING. Failed attempts are retried
+// with exponential backoff starting at ConnectBackoff, instead of the retry
+// policy.
+func (csr *cacheStoreRedis) connect(ctx context.Context) error {
+	backoff := csr.storeOptions.ConnectBackoff
+	pingCtx := withoutRetries(ctx)
+	var err error
+	for attempt := 1; attempt <= csr.storeOptions.ConnectAttempts; attempt++ {
+		if err = csr.redisClient.Ping(pingCtx).Err(); err == nil {
+			return nil
+		}
+		if attempt == csr.storeOptions.ConnectAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return &ConnectError{Store: csr.String(), Attempts: attempt, Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return &ConnectError{Store: csr.String(), Attempts: csr.storeOptions.ConnectAttempts, Err: err}
+}
+
+// parseInfo parses the "key:value" lines of an INFO reply
+func parseInfo(info string) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,99 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_ConnectCheck(t *testing.T) {
+	ctx := context.Background()
+
+	// setup and init store verifying the connection
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithConnectCheck(3, 10*time.Millisecond),
+	)
+	if err := cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_ConnectCheckFailure(t *testing.T) {
+	ctx := context.Background()
+
+	// nothing listens on port 1
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:1", MaxRetries: -1},
+		store.OptionWithConnectCheck(3, 10*time.Millisecond),
+	)
+
+	// Init must fail fast with a typed error
+	start := time.Now()
+	err := cacheStore.Init(ctx)
+	if err == nil {
+		t.Fatalf("expected error when connecting to invalid host, got nil")
+	}
+	var connectErr *store.ConnectError
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("expected *store.ConnectError, got %T", err)
+	}
+	if connectErr.Attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", connectErr.Attempts)
+	}
+	// backoff: 10ms + 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("expected backoff between attempts, took %s", elapsed)
+	}
+
+	// close connection (should not panic)
+	cacheStore.Close(ctx)
+}
+
+func TestCacheStore_HealthCheck(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 0)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// check health
+	status, err := cacheStore.HealthCheck(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Latency <= 0 {
+		t.Fatalf("expected positive latency, got %s", status.Latency)
+	}
+	if len(status.ServerVersion) == 0 {
+		t.Fatalf("expected server version")
+	}
+	if status.Role != "master" {
+		t.Fatalf("expected role master, got %q", status.Role)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// unhealthy store
+	cacheStore = store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.HealthCheck(ctx); err == nil {
+		t.Fatalf("expected error for unreachable server")
+	}
+	cacheStore.Close(ctx)
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gradientzero/comby/v2"
+)
+
+// loadLockPollInterval is the interval instances not holding the load lock
+// check for the loaded value
+const loadLockPollInterval = 25 * time.Millisecond
+
+// Loader computes the value of a missing cache entry
+type Loader func(ctx context.Context) (any, error)
+
+// releaseLockScript deletes the lock only if it is still held by the caller
+var releaseLockScript = newIdempotentScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (csr *cacheStoreRedis) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader) (*comby.CacheModel, error) {
+	if cacheModel, err := csr.get(ctx, csr.key(key), key); err != nil || cacheModel != nil {
+		return cacheModel, err
+	}
+	// the flight is shared by all callers, so it does not end when the
+	// caller starting it cancels but is limited by the load timeout
+	flight := csr.loadGroup.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), csr.storeOptions.LoadTimeout)
+		defer cancel()
+		// a previous flight may have loaded the value in the meantime
+		if cacheModel, err := csr.get(ctx, csr.key(key), key); err != nil || cacheModel != nil {
+			return cacheModel, err
+		}
+		if csr.storeOptions.LoadLock == nil {
+			return csr.load(ctx, key, ttl, loader)
+		}
+		return csr.loadLocked(ctx, key, ttl, loader)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-flight:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*comby.CacheModel), nil
+	}
+}
+
+// load calls loader and stores the loaded value
+func (csr *cacheStoreRedis) load(ctx context.Context, key string, ttl time.Duration, loader Loader) (*comby.CacheModel, error) {
+	value, err := loader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	valueToStore, err := csr.encodeEntry(key, value, ttl, csr.storeOptions.SoftTTL)
+	if err != nil {
+		return nil, err
+	}
+	// tags of a previous write no longer apply
+	if err := csr.untag(ctx, key); err != nil {
+		return nil, err
+	}
+	pipe := csr.redisClient.Pipeline()
+	setCmd := csr.queueSet(ctx, pipe, key, valueToStore, ttl)
+	if lockOpts := csr.storeOptions.LoadLock; lockOpts != nil && lockOpts.StaleTTL > 0 && ttl > 0 {
+		pipe.Set(ctx, csr.internalKey("stale", key), valueToStore, ttl+lockOpts.StaleTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, csr.quotaError(key, err)
+	}
+	if err := setCmd.Err(); err != nil {
+		return nil, err
+	}
+	csr.invalidate(ctx, append(csr.evictedKeys(setCmd), key)...)
+	cacheModel := &comby.CacheModel{
+		Key:   key,
+		Value: value,
+	}
+	if ttl > 0 {
+		cacheModel.ExpiredAt = time.Now().Add(ttl).UnixNano()
+	}
+	return cacheModel, nil
+}
+
+// loadLocked loads the value if this instance acquires the load lock.
+// Otherwise the stale copy is returned if available, or the value loaded by
+// the lock holder is awaited.
+func (csr *cacheStoreRedis) loadLocked(ctx context.Context, key string, ttl time.Duration, loader Loader) (*comby.CacheModel, error) {
+	lockOpts := csr.storeOptions.LoadLock
+	lockKey := csr.internalKey("lock", key)
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+	acquired, err := csr.redisClient.SetNX(ctx, lockKey, token, lockOpts.LockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if acquired {
+		defer releaseLockScript.Run(context.WithoutCancel(ctx), csr.redisClient, []string{lockKey}, token)
+		return csr.load(ctx, key, ttl, loader)
+	}
+
+	// another instance loads the value, serve the stale copy if available
+	if lockOpts.StaleTTL > 0 {
+		if cacheModel, err := csr.get(ctx, csr.internalKey("stale", key), key); err != nil || cacheModel != nil {
+			return cacheModel, err
+		}
+	}
+
+	// wait for the value loaded by the lock holder
+	deadline := time.Now().Add(lockOpts.WaitTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(loadLockPollInterval):
+		}
+		if cacheModel, err := csr.get(ctx, csr.key(key), key); err != nil || cacheModel != nil {
+			return cacheModel, err
+		}
+	}
+
+	// lock holder did not deliver in time
+	return csr.load(ctx, key, ttl, loader)
+}
+
+// lockToken returns a random token identifying the holder of a lock
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,247 @@
+package store_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_GetOrLoad(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 1)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent loads of the same key call the loader once
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return "loaded", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cacheModel, err := cacheStore.GetOrLoad(ctx, "loadKey", time.Minute, loader)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if cacheModel.Value != "loaded" {
+				t.Errorf("wrong value: %v", cacheModel.Value)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 loader call, got %d", calls.Load())
+	}
+
+	// loaded value is stored
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("loadKey"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel == nil || cacheModel.Value != "loaded" || cacheModel.ExpiredAt == 0 {
+		t.Fatalf("wrong cache model: %+v", cacheModel)
+	}
+
+	// loader errors are returned and nothing is stored
+	if _, err := cacheStore.GetOrLoad(ctx, "failKey", time.Minute, func(ctx context.Context) (any, error) {
+		return nil, context.DeadlineExceeded
+	}); err != context.DeadlineExceeded {
+		t.Fatalf("expected loader error, got %v", err)
+	}
+	if cacheModel, _ := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("failKey")); cacheModel != nil {
+		t.Fatalf("expected no entry, got %+v", cacheModel)
+	}
+
+	// entries loaded without ttl do not expire
+	if cacheModel, err := cacheStore.GetOrLoad(ctx, "persistentKey", 0, loader); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.ExpiredAt != 0 {
+		t.Fatalf("expected no expiration, got %d", cacheModel.ExpiredAt)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_GetOrLoadCanceled(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 1)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first caller starts the load and cancels while it is running
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (any, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return "loaded", nil
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cacheStore.GetOrLoad(cancelCtx, "loadKey", time.Minute, loader)
+		firstErr <- err
+	}()
+	<-started
+
+	// the second caller joins the running load
+	type result struct {
+		cacheModel *comby.CacheModel
+		err        error
+	}
+	second := make(chan result, 1)
+	go func() {
+		cacheModel, err := cacheStore.GetOrLoad(ctx, "loadKey", time.Minute, loader)
+		second <- result{cacheModel, err}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the canceled caller returns early, the load completes for the other
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	close(release)
+	if res := <-second; res.err != nil {
+		t.Fatal(res.err)
+	} else if res.cacheModel.Value != "loaded" {
+		t.Fatalf("wrong value: %v", res.cacheModel.Value)
+	}
+
+	// invalid options
+	if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithLoadTimeout(0),
+	); cacheStore != nil {
+		t.Fatalf("expected nil with invalid load timeout")
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_GetOrLoadLock(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// two stores simulate two instances sharing the Redis server
+	newStore := func() store.CacheStoreRedis {
+		cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+			store.OptionWithLoadLock(time.Second, 500*time.Millisecond, time.Minute),
+		)
+		if err = cacheStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return cacheStore
+	}
+	instance1, instance2 := newStore(), newStore()
+
+	// reset database
+	if err := instance1.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// instance1 holds the lock while loading, instance2 waits for its value
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := instance1.GetOrLoad(ctx, "lockKey", time.Minute, func(ctx context.Context) (any, error) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return "value1", nil
+		}); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-started
+	if cacheModel, err := instance2.GetOrLoad(ctx, "lockKey", time.Minute, func(ctx context.Context) (any, error) {
+		return "value2", nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "value1" {
+		t.Fatalf("expected value of lock holder, got %v", cacheModel.Value)
+	}
+	<-done
+
+	// lock and stale copy are not listed
+	if cacheModels, total, err := instance1.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 1 || total != 1 {
+		t.Fatalf("expected 1 entry, got %d (total %d)", len(cacheModels), total)
+	}
+
+	// after expiration instance2 receives the stale copy while instance1 reloads
+	if err := instance1.Delete(ctx, comby.CacheStoreDeleteOptionWithKey("lockKey")); err != nil {
+		t.Fatal(err)
+	}
+	started = make(chan struct{})
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := instance1.GetOrLoad(ctx, "lockKey", time.Minute, func(ctx context.Context) (any, error) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			return "value3", nil
+		}); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-started
+	if cacheModel, err := instance2.GetOrLoad(ctx, "lockKey", time.Minute, func(ctx context.Context) (any, error) {
+		return "value2", nil
+	}); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "value1" {
+		t.Fatalf("expected stale value, got %v", cacheModel.Value)
+	}
+	<-done
+
+	// invalid options
+	if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithLoadLock(0, time.Second, 0),
+	); cacheStore != nil {
+		t.Fatalf("expected nil with invalid lock ttl")
+	}
+
+	// close connections
+	instance1.Close(ctx)
+	instance2.Close(ctx)
+}
@@ -0,0 +1,382 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// nearCacheHealthCheck is the idle time after which the invalidation
+// subscription is pinged to detect broken connections
+const nearCacheHealthCheck = 30 * time.Second
+
+// NearCacheOptions configure the in-process cache in front of Redis. Values
+// served by the near cache are shared between callers and must not be
+// modified, e.g. maps and slices returned by Get.
+type NearCacheOptions struct {
+	// MaxEntries bounds the number of entries, the least recently used
+	// entries are evicted
+	MaxEntries int
+	// TTL caps how long an entry is served from the process, regardless of
+	// its expiration in Redis
+	TTL time.Duration
+}
+
+// TierStats are the hits and misses of a single cache tier
+type TierStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CacheStats reports the hits and misses of Get per tier. Lookups missing
+// the near cache are counted as hit or miss of Redis.
+type CacheStats struct {
+	Near  TierStats
+	Redis TierStats
+	// NearEntries is the number of entries currently held in process
+	NearEntries int
+	// NearEvictions is the number of entries evicted to stay within
+	// MaxEntries
+	NearEvictions uint64
+	// NearInvalidations is the number of invalidations received from other
+	// instances
+	NearInvalidations uint64
+}
+
+// nearInvalidation is published on every write, so that other instances
+// drop the written keys from their near cache
+type nearInvalidation struct {
+	// Origin identifies the publishing instance, which already invalidated
+	Origin string   `json:"o"`
+	Keys   []string `json:"k,omitempty"`
+	// All drops all entries, e.g. after Reset
+	All bool `json:"a,omitempty"`
+}
+
+type nearEntry struct {
+	key       string
+	entry     *Entry
+	expiresAt int64
+}
+
+// nearCache is a bounded LRU of decoded entries
+type nearCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]*list.Element
+	lru        *list.List
+	// generation is incremented by every invalidation, entries fetched
+	// before are not added
+	generation uint64
+	// online is false while invalidations may be missed, e.g. while the
+	// subscription reconnects, the near cache is bypassed meanwhile
+	online bool
+
+	hits, misses, evictions, invalidations atomic.Uint64
+}
+
+func newNearCache(opts *NearCacheOptions) *nearCache {
+	return &nearCache{
+		maxEntries: opts.MaxEntries,
+		ttl:        opts.TTL,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns a copy of the entry of key if present and not expired. The
+// copy is shallow, the value itself is shared with the cache and all other
+// callers and thus read-only.
+func (nc *nearCache) get(key string) (*Entry, bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	elem, ok := nc.entries[key]
+	if !ok || !nc.online {
+		nc.misses.Add(1)
+		return nil, false
+	}
+	cached := elem.Value.(*nearEntry)
+	now := time.Now().UnixNano()
+	if now >= cached.expiresAt {
+		nc.lru.Remove(elem)
+		delete(nc.entries, key)
+		nc.misses.Add(1)
+		return nil, false
+	}
+	nc.lru.MoveToFront(elem)
+	nc.hits.Add(1)
+
+	entry := copyEntry(cached.entry)
+	if entry.header != nil {
+		entry.Stale = now >= entry.StaleAt
+	}
+	return entry, true
+}
+
+// copyEntry returns a copy of entry and its cache model, so callers changing
+// the returned model do not change the cached one
+func copyEntry(entry *Entry) *Entry {
+	cacheModel := *entry.CacheModel
+	copied := *entry
+	copied.CacheModel = &cacheModel
+	return &copied
+}
+
+// currentGeneration returns the generation to pass to add for entries
+// fetched from now on
+func (nc *nearCache) currentGeneration() uint64 {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return nc.generation
+}
+
+// add stores entry unless an invalidation happened since generation, as the
+// entry may have been fetched before the write it was invalidated for
+func (nc *nearCache) add(key string, entry *Entry, generation uint64) {
+	expiresAt := time.Now().Add(nc.ttl).UnixNano()
+	if expiredAt := entry.CacheModel.ExpiredAt; expiredAt > 0 && expiredAt < expiresAt {
+		expiresAt = expiredAt
+	}
+
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if generation != nc.generation || !nc.online {
+		return
+	}
+	cached := &nearEntry{key: key, entry: copyEntry(entry), expiresAt: expiresAt}
+	if elem, ok := nc.entries[key]; ok {
+		elem.Value = cached
+		nc.lru.MoveToFront(elem)
+		return
+	}
+	nc.entries[key] = nc.lru.PushFront(cached)
+	for nc.lru.Len() > nc.maxEntries {
+		oldest := nc.lru.Back()
+		nc.lru.Remove(oldest)
+		delete(nc.entries, oldest.Value.(*nearEntry).key)
+		nc.evictions.Add(1)
+	}
+}
+
+// invalidate drops the given keys
+func (nc *nearCache) invalidate(keys ...string) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	nc.generation++
+	for _, key := range keys {
+		if elem, ok := nc.entries[key]; ok {
+			nc.lru.Remove(elem)
+			delete(nc.entries, key)
+		}
+	}
+}
+
+// flush drops all entries
+func (nc *nearCache) flush() {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	nc.flushLocked()
+}
+
+func (nc *nearCache) flushLocked() {
+	nc.generation++
+	nc.entries = make(map[string]*list.Element)
+	nc.lru.Init()
+}
+
+// setOnline drops all entries and enables or bypasses the near cache
+func (nc *nearCache) setOnline(online bool) {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	nc.flushLocked()
+	nc.online = online
+}
+
+func (nc *nearCache) len() int {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return nc.lru.Len()
+}
+
+func (csr *cacheStoreRedis) Stats() *CacheStats {
+	stats := &CacheStats{
+		Redis: TierStats{
+			Hits:   csr.redisHits.Load(),
+			Misses: csr.redisMisses.Load(),
+		},
+	}
+	if nc := csr.nearCache; nc != nil {
+		stats.Near = TierStats{Hits: nc.hits.Load(), Misses: nc.misses.Load()}
+		stats.NearEntries = nc.len()
+		stats.NearEvictions = nc.evictions.Load()
+		stats.NearInvalidations = nc.invalidations.Load()
+	}
+	return stats
+}
+
+// nearChannel is the channel invalidations are published on
+func (csr *cacheStoreRedis) nearChannel() string {
+	return csr.storeOptions.Namespace + internalKeyPrefix + "invalidate"
+}
+
+// startNearCache creates the near cache and subscribes to invalidations,
+// published by other instances or, using client tracking, by the server
+func (csr *cacheStoreRedis) startNearCache(ctx context.Context) error {
+	origin, err := lockToken()
+	if err != nil {
+		return err
+	}
+	csr.nearOrigin = origin
+	csr.nearCache = newNearCache(csr.storeOptions.NearCache)
+	if csr.storeOptions.ClientTracking {
+		csr.trackingClient = csr.newTrackingClient()
+		csr.nearPubSub = csr.trackingClient.Subscribe(ctx, trackingChannel)
+	} else {
+		csr.nearPubSub = csr.redisClient.Subscribe(ctx, csr.nearChannel())
+	}
+
+	// wait for the subscription, entries are only cached once
+	// invalidations are received
+	msg, err := csr.nearPubSub.Receive(ctx)
+	if _, ok := msg.(*redis.Subscription); err == nil && !ok {
+		err = fmt.Errorf("unexpected message %v", msg)
+	}
+	if err != nil {
+		csr.nearPubSub.Close()
+		csr.nearPubSub = nil
+		if csr.trackingClient != nil {
+			csr.trackingClient.Close()
+			csr.trackingClient = nil
+		}
+		return err
+	}
+	csr.nearCache.setOnline(true)
+
+	csr.nearDone = make(chan struct{})
+	csr.nearWG.Add(1)
+	go csr.receiveInvalidations()
+	return nil
+}
+
+// stopNearCache unsubscribes from invalidations
+func (csr *cacheStoreRedis) stopNearCache() error {
+	if csr.nearPubSub == nil {
+		return nil
+	}
+	close(csr.nearDone)
+	err := csr.nearPubSub.Close()
+	csr.nearWG.Wait()
+	csr.nearPubSub = nil
+	if csr.trackingClient != nil {
+		if closeErr := csr.trackingClient.Close(); err == nil {
+			err = closeErr
+		}
+		csr.trackingClient = nil
+	}
+	return err
+}
+
+// receiveInvalidations applies invalidations published by other instances
+// or the server. Invalidations published while disconnected are lost, so
+// the near cache is bypassed until the subscription is reestablished.
+func (csr *cacheStoreRedis) receiveInvalidations() {
+	defer csr.nearWG.Done()
+	ctx := context.Background()
+	backoff := 100 * time.Millisecond
+	for {
+		msg, err := csr.nearPubSub.ReceiveTimeout(ctx, nearCacheHealthCheck)
+		select {
+		case <-csr.nearDone:
+			return
+		default:
+		}
+		var netErr net.Error
+		switch {
+		case errors.As(err, &netErr) && netErr.Timeout():
+			// idle, a broken connection fails the ping and is reconnected
+			csr.nearPubSub.Ping(ctx)
+			continue
+		case err != nil:
+			// the message is lost, e.g. flushes are delivered by client
+			// tracking with a null payload not supported by go-redis
+			csr.nearCache.flush()
+			if err := csr.nearPubSub.Ping(ctx); err == nil {
+				continue
+			}
+			csr.nearCache.setOnline(false)
+			select {
+			case <-csr.nearDone:
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			csr.nearCache.setOnline(true)
+		case *redis.Message:
+			if msg.Channel == trackingChannel {
+				csr.applyTracking(msg)
+				continue
+			}
+			var invalidation nearInvalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+				// unknown message, stay on the safe side
+				csr.nearCache.flush()
+				continue
+			}
+			if invalidation.Origin == csr.nearOrigin {
+				continue
+			}
+			csr.nearCache.invalidations.Add(1)
+			if invalidation.All {
+				csr.nearCache.flush()
+			} else {
+				csr.nearCache.invalidate(invalidation.Keys...)
+			}
+		}
+	}
+}
+
+// invalidate drops the given keys from the near cache of this and, by
+// publishing an invalidation, all other instances. It must be called after
+// the write completed. Failing to publish leaves other instances serving
+// the previous value for at most the TTL of their near cache.
+func (csr *cacheStoreRedis) invalidate(ctx context.Context, keys ...string) {
+	if csr.nearCache == nil || len(keys) == 0 {
+		return
+	}
+	csr.nearCache.invalidate(keys...)
+	csr.publishInvalidation(ctx, &nearInvalidation{Origin: csr.nearOrigin, Keys: keys})
+}
+
+// invalidateAll drops all entries from the near cache of all instances
+func (csr *cacheStoreRedis) invalidateAll(ctx context.Context) {
+	if csr.nearCache == nil {
+		return
+	}
+	csr.nearCache.flush()
+	csr.publishInvalidation(ctx, &nearInvalidation{Origin: csr.nearOrigin, All: true})
+}
+
+func (csr *cacheStoreRedis) publishInvalidation(ctx context.Context, invalidation *nearInvalidation) {
+	if csr.storeOptions.ClientTracking {
+		// the server invalidates the keys of all instances
+		return
+	}
+	payload, err := json.Marshal(invalidation)
+	if err != nil {
+		return
+	}
+	csr.redisClient.Publish(context.WithoutCancel(ctx), csr.nearChannel(), payload)
+}
@@ -0,0 +1,137 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_NearCache(t *testing.T) {
+	ctx := context.Background()
+
+	// setup and init two instances sharing the database
+	newStore := func() store.CacheStoreRedis {
+		cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+			store.OptionWithNearCache(3, time.Minute),
+		)
+		if err := cacheStore.Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return cacheStore
+	}
+	cacheStore1 := newStore()
+	cacheStore2 := newStore()
+
+	// reset database
+	if err := cacheStore1.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	getValue := func(cacheStore store.CacheStoreRedis, key string) any {
+		cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cacheModel == nil {
+			return nil
+		}
+		return cacheModel.Value
+	}
+	waitFor := func(condition func() bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("condition not met in time")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForValue := func(cacheStore store.CacheStoreRedis, key string, value any) {
+		waitFor(func() bool { return getValue(cacheStore, key) == value })
+	}
+
+	// second read is served in process, once invalidations of reset and set
+	// were delivered
+	if err := cacheStore1.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "v1")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func() bool { return cacheStore2.Stats().NearInvalidations >= 2 })
+	waitForValue(cacheStore2, "key", "v1")
+	before := cacheStore2.Stats()
+	if value := getValue(cacheStore2, "key"); value != "v1" {
+		t.Fatalf("wrong value: %v", value)
+	}
+	if after := cacheStore2.Stats(); after.Near.Hits != before.Near.Hits+1 || after.Redis.Hits != before.Redis.Hits {
+		t.Fatalf("expected near cache hit: %+v", after)
+	}
+
+	// writes of other instances invalidate the entry
+	if err := cacheStore1.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "v2")); err != nil {
+		t.Fatal(err)
+	}
+	waitForValue(cacheStore2, "key", "v2")
+	if stats := cacheStore2.Stats(); stats.NearInvalidations == 0 {
+		t.Fatalf("expected invalidations: %+v", stats)
+	}
+
+	// own writes are visible immediately
+	if err := cacheStore2.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "v3")); err != nil {
+		t.Fatal(err)
+	}
+	if value := getValue(cacheStore2, "key"); value != "v3" {
+		t.Fatalf("wrong value: %v", value)
+	}
+
+	// changing a model returned by a miss does not change the cached entry
+	if err := cacheStore2.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("copied", "v1")); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModel, err := cacheStore2.Get(ctx, comby.CacheStoreGetOptionWithKey("copied")); err != nil {
+		t.Fatal(err)
+	} else {
+		cacheModel.Key, cacheModel.Value = "changed", "changed"
+	}
+	if cacheModel, err := cacheStore2.Get(ctx, comby.CacheStoreGetOptionWithKey("copied")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Key != "copied" || cacheModel.Value != "v1" {
+		t.Fatalf("expected unchanged entry, got %+v", cacheModel)
+	}
+
+	// deletes and resets invalidate as well
+	if err := cacheStore1.Delete(ctx, comby.CacheStoreDeleteOptionWithKey("key")); err != nil {
+		t.Fatal(err)
+	}
+	waitForValue(cacheStore2, "key", nil)
+	if err := cacheStore1.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("key", "v4")); err != nil {
+		t.Fatal(err)
+	}
+	waitForValue(cacheStore2, "key", "v4")
+	if err := cacheStore1.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitForValue(cacheStore2, "key", nil)
+
+	// near cache is bounded
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if err := cacheStore1.Set(ctx, comby.CacheStoreSetOptionWithKeyValue(key, i)); err != nil {
+			t.Fatal(err)
+		}
+		getValue(cacheStore1, key)
+	}
+	if stats := cacheStore1.Stats(); stats.NearEntries != 3 || stats.NearEvictions != 2 {
+		t.Fatalf("expected bounded near cache: %+v", stats)
+	}
+
+	// close connections
+	for _, cacheStore := range []store.CacheStoreRedis{cacheStore1, cacheStore2} {
+		if err := cacheStore.Close(ctx); err != nil {
+			t.Fatalf("failed to close connection: %v", err)
+		}
+	}
+}
@@ -0,0 +1,631 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gradientzero/comby/v2"
+)
+
+// defaultScanCount is the COUNT hint passed to SCAN if not configured otherwise
+const defaultScanCount int64 = 1000
+
+// defaultLoadTimeout limits loads of GetOrLoad if not configured otherwise
+const defaultLoadTimeout = 30 * time.Second
+
+// Options holds redis specific settings of the cache store
+type Options struct {
+	// CacheStoreOptions are applied to the underlying comby.CacheStoreOptions
+	CacheStoreOptions []comby.CacheStoreOption
+	// ScanCount is the COUNT hint used for SCAN and thus the size of each
+	// batch of values fetched within a single round trip
+	ScanCount int64
+	// Namespace is prepended to every key written by this store. If set, the
+	// store no longer assumes to own the whole database: Reset, Total and
+	// Info are scoped to the keys of the namespace.
+	Namespace string
+	// Codec serializes values before they are stored, defaults to NewJSONCodec
+	Codec Codec
+	// ReadFromReplicas routes Get and List to replicas (Sentinel or Cluster)
+	ReadFromReplicas bool
+	// TLS enables TLS for the Redis connection if set
+	TLS *TLSOptions
+	// ConnectAttempts enables verifying the connection in Init, which fails
+	// with *ConnectError if no PING succeeded within the given attempts
+	ConnectAttempts int
+	// ConnectBackoff is the delay before the second attempt, doubled for
+	// every further attempt
+	ConnectBackoff time.Duration
+	// LoadLock enables a distributed lock for GetOrLoad if set
+	LoadLock *LoadLockOptions
+	// LoadTimeout limits a load of GetOrLoad, which is shared by concurrent
+	// callers and thus not canceled with the context of a single caller
+	LoadTimeout time.Duration
+	// SoftTTL is the default soft TTL of entries, 0 disables it
+	SoftTTL time.Duration
+	// Refresher reloads stale entries in background if set
+	Refresher Refresher
+	// RefreshTimeout limits the duration of a background refresh
+	RefreshTimeout time.Duration
+	// RefreshAhead is the fraction of the soft TTL after which entries are
+	// refreshed ahead of becoming stale, 0 disables it
+	RefreshAhead float64
+	// Tags enables SetOptionWithTags and InvalidateTags
+	Tags bool
+	// TenantResolver enables the tenant index if set
+	TenantResolver TenantResolver
+	// Quota enables per-tenant quotas if set
+	Quota *QuotaOptions
+	// Keyring encrypts values in an envelope carrying the key ID if set
+	Keyring *Keyring
+	// AllowUnboundCiphertext accepts encrypted values not bound to their
+	// key, as written by previous versions
+	AllowUnboundCiphertext bool
+	// TenantKeys encrypt the values of each tenant with its own key if set
+	TenantKeys *TenantKeyOptions
+	// Compression compresses values before encryption, CompressionNone
+	// disables it
+	Compression Compression
+	// CompressionThreshold is the minimum size in bytes of values to compress
+	CompressionThreshold int
+	// MaxDecompressedSize limits the size in bytes of decompressed values,
+	// protecting against corrupt or hostile values
+	MaxDecompressedSize int64
+	// NearCache enables an in-process cache in front of Redis if set
+	NearCache *NearCacheOptions
+	// ClientTracking invalidates the near cache using server-assisted
+	// client side caching instead of pub/sub
+	ClientTracking bool
+	// CircuitBreaker rejects calls while Redis is unavailable if set
+	CircuitBreaker *CircuitBreakerOptions
+	// Fallback serves Get, Set, List, Delete, Total and Reset while the
+	// circuit is open if set
+	Fallback comby.CacheStore
+	// RetryPolicy retries failed calls instead of go-redis if set
+	RetryPolicy *RetryPolicy
+}
+
+// TenantKeyOptions configure per-tenant encryption keys
+type TenantKeyOptions struct {
+	Provider TenantKeyProvider
+	Resolver TenantResolver
+}
+
+// LoadLockOptions configure the distributed lock taken by GetOrLoad, so that
+// only a single instance loads a missing entry
+type LoadLockOptions struct {
+	// LockTTL is the expiration of the lock, it should exceed the duration
+	// of a load
+	LockTTL time.Duration
+	// WaitTimeout is how long instances not holding the lock wait for the
+	// loaded value before loading it themselves
+	WaitTimeout time.Duration
+	// StaleTTL keeps a copy of loaded values for ttl + StaleTTL, returned
+	// to instances not holding the lock instead of waiting; 0 disables it
+	StaleTTL time.Duration
+}
+
+type Option func(opt *Options) (*Options, error)
+
+func OptionWithCacheStoreOptions(opts ...comby.CacheStoreOption) Option {
+	return func(o *Options) (*Options, error) {
+		o.CacheStoreOptions = append(o.CacheStoreOptions, opts...)
+		return o, nil
+	}
+}
+
+func OptionWithScanCount(scanCount int64) Option {
+	return func(o *Options) (*Options, error) {
+		if scanCount < 1 {
+			return nil, fmt.Errorf("invalid scan count %d", scanCount)
+		}
+		o.ScanCount = scanCount
+		return o, nil
+	}
+}
+
+// OptionWithNamespace prefixes all keys with the given namespace, e.g. "cache:"
+func OptionWithNamespace(namespace string) Option {
+	return func(o *Options) (*Options, error) {
+		if len(namespace) == 0 {
+			return nil, fmt.Errorf("invalid namespace %q", namespace)
+		}
+		o.Namespace = namespace
+		return o, nil
+	}
+}
+
+func OptionWithCodec(codec Codec) Option {
+	return func(o *Options) (*Options, error) {
+		if codec == nil {
+			return nil, fmt.Errorf("invalid codec")
+		}
+		o.Codec = codec
+		return o, nil
+	}
+}
+
+// OptionWithReadFromReplicas routes reads of Get and List to replicas. Note
+// that replicas may lag behind the master.
+func OptionWithReadFromReplicas() Option {
+	return func(o *Options) (*Options, error) {
+		o.ReadFromReplicas = true
+		return o, nil
+	}
+}
+
+// OptionWithConnectCheck makes Init verify the connection using PING,
+// retrying up to attempts times with exponential backoff
+func OptionWithConnectCheck(attempts int, backoff time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if attempts < 1 {
+			return nil, fmt.Errorf("invalid connect attempts %d", attempts)
+		}
+		if backoff < 0 {
+			return nil, fmt.Errorf("invalid connect backoff %s", backoff)
+		}
+		o.ConnectAttempts = attempts
+		o.ConnectBackoff = backoff
+		return o, nil
+	}
+}
+
+func OptionWithLoadLock(lockTTL, waitTimeout, staleTTL time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if lockTTL < time.Millisecond {
+			return nil, fmt.Errorf("invalid lock ttl %s", lockTTL)
+		}
+		if waitTimeout < 0 || staleTTL < 0 {
+			return nil, fmt.Errorf("invalid wait timeout %s or stale ttl %s", waitTimeout, staleTTL)
+		}
+		o.LoadLock = &LoadLockOptions{
+			LockTTL:     lockTTL,
+			WaitTimeout: waitTimeout,
+			StaleTTL:    staleTTL,
+		}
+		return o, nil
+	}
+}
+
+// OptionWithLoadTimeout limits the duration of a load of GetOrLoad including
+// the loader call, defaults to 30s
+func OptionWithLoadTimeout(timeout time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if timeout <= 0 {
+			return nil, fmt.Errorf("invalid load timeout %s", timeout)
+		}
+		o.LoadTimeout = timeout
+		return o, nil
+	}
+}
+
+// OptionWithSoftTTL sets the default soft TTL of entries written by Set,
+// SetMany and GetOrLoad. Entries past their soft TTL are returned as stale
+// until they expire and refreshed in background using the Refresher.
+func OptionWithSoftTTL(softTTL time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if softTTL < 0 {
+			return nil, fmt.Errorf("invalid soft ttl %s", softTTL)
+		}
+		o.SoftTTL = softTTL
+		return o, nil
+	}
+}
+
+// OptionWithRefresher registers the refresher reloading stale entries, each
+// refresh is limited to timeout
+func OptionWithRefresher(refresher Refresher, timeout time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if refresher == nil {
+			return nil, fmt.Errorf("refresher is nil")
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("invalid refresh timeout %s", timeout)
+		}
+		o.Refresher = refresher
+		o.RefreshTimeout = timeout
+		return o, nil
+	}
+}
+
+// OptionWithRefreshAhead refreshes entries read after the given fraction of
+// their soft TTL, e.g. 0.8, before they become stale
+func OptionWithRefreshAhead(ratio float64) Option {
+	return func(o *Options) (*Options, error) {
+		if ratio <= 0 || ratio >= 1 {
+			return nil, fmt.Errorf("invalid refresh ahead ratio %v", ratio)
+		}
+		o.RefreshAhead = ratio
+		return o, nil
+	}
+}
+
+// OptionWithTags enables tagging entries using SetOptionWithTags and
+// InvalidateTags. Entries are then removed from their tags when rewritten
+// without tags or deleted, which costs a script call per write. Not
+// supported on Redis Cluster.
+func OptionWithTags() Option {
+	return func(o *Options) (*Options, error) {
+		o.Tags = true
+		return o, nil
+	}
+}
+
+// OptionWithTenantIndex records the keys of each tenant in a sorted set
+// indexed by expiration, so listing and counting a tenant is O(tenant size)
+// and only returns keys resolved to that tenant. Keys the resolver can not
+// resolve are recorded in a separate index, which is scanned for keys
+// prefixed "tenantUuid-" when listing and counting. Resolver defaults to
+// TenantResolverUUIDPrefix if nil. Only entries written with the index
+// enabled are indexed.
+func OptionWithTenantIndex(resolver TenantResolver) Option {
+	return func(o *Options) (*Options, error) {
+		if resolver == nil {
+			resolver = TenantResolverUUIDPrefix()
+		}
+		o.TenantResolver = resolver
+		return o, nil
+	}
+}
+
+// OptionWithTenantQuota limits the keys and bytes of each tenant, 0 means
+// unlimited. Writes exceeding the quota are rejected with ErrQuotaExceeded
+// or evict the tenant's least recently written entries, depending on policy.
+// Quotas require the tenant index and are not supported on Redis Cluster.
+func OptionWithTenantQuota(maxKeys, maxBytes int64, policy QuotaPolicy) Option {
+	return func(o *Options) (*Options, error) {
+		if maxKeys < 0 || maxBytes < 0 {
+			return nil, fmt.Errorf("invalid quota %d keys, %d bytes", maxKeys, maxBytes)
+		}
+		switch policy {
+		case QuotaPolicyReject, QuotaPolicyEvictOldest:
+		default:
+			return nil, fmt.Errorf("invalid quota policy %d", policy)
+		}
+		if o.Quota == nil {
+			o.Quota = &QuotaOptions{}
+		}
+		o.Quota.Default = QuotaLimits{MaxKeys: maxKeys, MaxBytes: maxBytes}
+		o.Quota.Policy = policy
+		return o, nil
+	}
+}
+
+// OptionWithTenantQuotaOverride sets the limits of a single tenant, the
+// policy of OptionWithTenantQuota applies
+func OptionWithTenantQuotaOverride(tenantUuid string, maxKeys, maxBytes int64) Option {
+	return func(o *Options) (*Options, error) {
+		if maxKeys < 0 || maxBytes < 0 {
+			return nil, fmt.Errorf("invalid quota %d keys, %d bytes", maxKeys, maxBytes)
+		}
+		if o.Quota == nil {
+			o.Quota = &QuotaOptions{}
+		}
+		if o.Quota.Tenants == nil {
+			o.Quota.Tenants = make(map[string]QuotaLimits)
+		}
+		o.Quota.Tenants[tenantUuid] = QuotaLimits{MaxKeys: maxKeys, MaxBytes: maxBytes}
+		return o, nil
+	}
+}
+
+// OptionWithEncryptionKey encrypts new values using cipher, e.g. a
+// comby.CryptoService, identified by keyID in the stored envelope. Values
+// encrypted by the comby crypto service without envelope remain readable.
+func OptionWithEncryptionKey(keyID string, cipher Cipher) Option {
+	return func(o *Options) (*Options, error) {
+		if err := addKey(o, keyID, cipher); err != nil {
+			return nil, err
+		}
+		o.Keyring.CurrentKeyID = keyID
+		return o, nil
+	}
+}
+
+// OptionWithRetiredEncryptionKey accepts cipher identified by keyID for
+// decryption only, e.g. the previous key after a rotation
+func OptionWithRetiredEncryptionKey(keyID string, cipher Cipher) Option {
+	return func(o *Options) (*Options, error) {
+		if err := addKey(o, keyID, cipher); err != nil {
+			return nil, err
+		}
+		return o, nil
+	}
+}
+
+func addKey(o *Options, keyID string, cipher Cipher) error {
+	if len(keyID) == 0 || len(keyID) > 255 {
+		return fmt.Errorf("invalid key id '%s'", keyID)
+	}
+	if cipher == nil {
+		return fmt.Errorf("cipher of key id '%s' is nil", keyID)
+	}
+	if o.Keyring == nil {
+		o.Keyring = &Keyring{Keys: make(map[string]Cipher)}
+	}
+	o.Keyring.Keys[keyID] = cipher
+	return nil
+}
+
+// OptionWithUnboundCiphertext accepts encrypted values written by previous
+// versions, which are not bound to their key. Use it during migration only,
+// as such values can be copied to other keys unnoticed; Reencrypt binds them.
+func OptionWithUnboundCiphertext() Option {
+	return func(o *Options) (*Options, error) {
+		o.AllowUnboundCiphertext = true
+		return o, nil
+	}
+}
+
+// OptionWithTenantKeyProvider encrypts the values of each tenant with the
+// cipher returned by provider, the tenant is resolved from the key using
+// resolver, TenantResolverUUIDPrefix if nil. Values of keys without tenant
+// are encrypted with the keyring or comby crypto service, if configured.
+func OptionWithTenantKeyProvider(provider TenantKeyProvider, resolver TenantResolver) Option {
+	return func(o *Options) (*Options, error) {
+		if provider == nil {
+			return nil, fmt.Errorf("tenant key provider is nil")
+		}
+		if resolver == nil {
+			resolver = TenantResolverUUIDPrefix()
+		}
+		o.TenantKeys = &TenantKeyOptions{Provider: provider, Resolver: resolver}
+		return o, nil
+	}
+}
+
+// OptionWithCompression compresses values of at least threshold bytes
+// using the given algorithm. Values are compressed before they are
+// encrypted and only stored compressed if it reduces their size.
+func OptionWithCompression(algorithm Compression, threshold int) Option {
+	return func(o *Options) (*Options, error) {
+		if algorithm > CompressionSnappy {
+			return nil, fmt.Errorf("invalid compression %s", algorithm)
+		}
+		if threshold < 0 {
+			return nil, fmt.Errorf("invalid compression threshold %d", threshold)
+		}
+		o.Compression = algorithm
+		o.CompressionThreshold = threshold
+		return o, nil
+	}
+}
+
+// OptionWithMaxDecompressedSize limits the size of decompressed values,
+// larger values fail to decode. Defaults to 64 MiB.
+func OptionWithMaxDecompressedSize(maxSize int64) Option {
+	return func(o *Options) (*Options, error) {
+		if maxSize < 1 {
+			return nil, fmt.Errorf("invalid max decompressed size %d", maxSize)
+		}
+		o.MaxDecompressedSize = maxSize
+		return o, nil
+	}
+}
+
+// OptionWithNearCache serves Get from an in-process LRU of at most
+// maxEntries entries, each kept for at most ttl. Writes of all instances
+// sharing the Redis server invalidate the entry using pub/sub. Values are
+// shared between callers and must be treated as read-only.
+func OptionWithNearCache(maxEntries int, ttl time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if maxEntries < 1 {
+			return nil, fmt.Errorf("invalid near cache size %d", maxEntries)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("invalid near cache ttl %v", ttl)
+		}
+		o.NearCache = &NearCacheOptions{MaxEntries: maxEntries, TTL: ttl}
+		return o, nil
+	}
+}
+
+// OptionWithClientTracking invalidates the near cache enabled using
+// OptionWithNearCache by the server itself (CLIENT TRACKING in broadcasting
+// mode on the namespace) instead of publishing invalidations on writes.
+// Requires Redis 6 or later, not supported on Redis Cluster.
+func OptionWithClientTracking() Option {
+	return func(o *Options) (*Options, error) {
+		o.ClientTracking = true
+		return o, nil
+	}
+}
+
+// OptionWithCircuitBreaker opens the circuit once failureRate of at least
+// minRequests calls within window failed due to Redis being unavailable.
+// Calls are then rejected with ErrCircuitOpen, until a single probe passed
+// after openTimeout succeeds.
+func OptionWithCircuitBreaker(failureRate float64, minRequests int, window, openTimeout time.Duration) Option {
+	return func(o *Options) (*Options, error) {
+		if failureRate <= 0 || failureRate > 1 {
+			return nil, fmt.Errorf("invalid failure rate %v", failureRate)
+		}
+		if minRequests < 1 {
+			return nil, fmt.Errorf("invalid min requests %d", minRequests)
+		}
+		if window <= 0 || openTimeout <= 0 {
+			return nil, fmt.Errorf("invalid circuit breaker window %v or open timeout %v", window, openTimeout)
+		}
+		o.CircuitBreaker = &CircuitBreakerOptions{
+			FailureRate: failureRate,
+			MinRequests: minRequests,
+			Window:      window,
+			OpenTimeout: openTimeout,
+		}
+		return o, nil
+	}
+}
+
+// OptionWithFallback serves Get, Set, List, Delete, Total and Reset by the
+// given initialized store, e.g. comby.NewCacheStoreMemory(), while the
+// circuit breaker is open. Once the circuit closes, keys written to the
+// fallback store meanwhile are removed from Redis and the fallback store is
+// reset.
+func OptionWithFallback(fallback comby.CacheStore) Option {
+	return func(o *Options) (*Options, error) {
+		if fallback == nil {
+			return nil, fmt.Errorf("fallback store is nil")
+		}
+		o.Fallback = fallback
+		return o, nil
+	}
+}
+
+// OptionWithRetryPolicy retries calls to Redis failing with one of the
+// given error classes, ErrUnavailable and ErrTimeout if none given, up to
+// maxAttempts attempts in total. The delay between attempts starts at
+// initialBackoff, doubles up to maxBackoff and is randomized between half
+// and the full delay. The policy replaces the retries of go-redis. Calls
+// which are not idempotent, e.g. writes subject to a tenant quota, are not
+// retried, neither is the connection check of OptionWithConnectCheck.
+func OptionWithRetryPolicy(maxAttempts int, initialBackoff, maxBackoff time.Duration, retryOn ...error) Option {
+	return func(o *Options) (*Options, error) {
+		if maxAttempts < 1 {
+			return nil, fmt.Errorf("invalid max attempts %d", maxAttempts)
+		}
+		if initialBackoff <= 0 || maxBackoff < initialBackoff {
+			return nil, fmt.Errorf("invalid backoff %v to %v", initialBackoff, maxBackoff)
+		}
+		if len(retryOn) == 0 {
+			retryOn = []error{ErrUnavailable, ErrTimeout}
+		}
+		o.RetryPolicy = &RetryPolicy{
+			MaxAttempts:    maxAttempts,
+			InitialBackoff: initialBackoff,
+			MaxBackoff:     maxBackoff,
+			RetryOn:        retryOn,
+		}
+		return o, nil
+	}
+}
+
+// SetOptions define the cache entry written by SetWithOptions
+type SetOptions struct {
+	Key   string
+	Value any
+	// Expiration is the hard TTL of the entry, 0 means no expiration
+	Expiration time.Duration
+	// SoftTTL marks the entry stale after this duration, 0 disables it
+	SoftTTL time.Duration
+	// Tags of the entry, see InvalidateTags
+	Tags []string
+}
+
+type SetOption func(opt *SetOptions) (*SetOptions, error)
+
+func SetOptionWithKeyValue(key string, value any) SetOption {
+	return func(o *SetOptions) (*SetOptions, error) {
+		o.Key = key
+		o.Value = value
+		return o, nil
+	}
+}
+
+func SetOptionWithExpiration(expiration time.Duration) SetOption {
+	return func(o *SetOptions) (*SetOptions, error) {
+		if expiration < 0 {
+			return nil, fmt.Errorf("invalid expiration %s", expiration)
+		}
+		o.Expiration = expiration
+		return o, nil
+	}
+}
+
+func SetOptionWithSoftTTL(softTTL time.Duration) SetOption {
+	return func(o *SetOptions) (*SetOptions, error) {
+		if softTTL < 0 {
+			return nil, fmt.Errorf("invalid soft ttl %s", softTTL)
+		}
+		o.SoftTTL = softTTL
+		return o, nil
+	}
+}
+
+// SetOptionWithTags tags the entry, replacing the tags of a previous write.
+// Requires OptionWithTags.
+func SetOptionWithTags(tags ...string) SetOption {
+	return func(o *SetOptions) (*SetOptions, error) {
+		for _, tag := range tags {
+			if len(tag) == 0 {
+				return nil, fmt.Errorf("invalid empty tag")
+			}
+		}
+		o.Tags = tags
+		return o, nil
+	}
+}
+
+const (
+	// ListOrderByKey orders cache entries lexicographically by key
+	ListOrderByKey = "key"
+	// ListOrderByTTL orders cache entries by remaining time to live,
+	// entries without expiration are considered to expire last
+	ListOrderByTTL = "ttl"
+)
+
+// ListOptions define which page of cache entries ListWithOptions returns
+type ListOptions struct {
+	TenantUuid string
+	Offset     int64
+	Limit      int64
+	OrderBy    string
+	Ascending  bool
+}
+
+type ListOption func(opt *ListOptions) (*ListOptions, error)
+
+func ListOptionWithTenantUuid(tenantUuid string) ListOption {
+	return func(o *ListOptions) (*ListOptions, error) {
+		o.TenantUuid = tenantUuid
+		return o, nil
+	}
+}
+
+func ListOptionWithOffset(offset int64) ListOption {
+	return func(o *ListOptions) (*ListOptions, error) {
+		if offset < 0 {
+			return nil, fmt.Errorf("invalid offset %d", offset)
+		}
+		o.Offset = offset
+		return o, nil
+	}
+}
+
+// ListOptionWithLimit limits the number of returned entries, 0 means no limit
+func ListOptionWithLimit(limit int64) ListOption {
+	return func(o *ListOptions) (*ListOptions, error) {
+		if limit < 0 {
+			return nil, fmt.Errorf("invalid limit %d", limit)
+		}
+		o.Limit = limit
+		return o, nil
+	}
+}
+
+// ListOptionWithPage sets offset and limit for the given 1-based page
+func ListOptionWithPage(page, pageSize int64) ListOption {
+	return func(o *ListOptions) (*ListOptions, error) {
+		if page < 1 {
+			return nil, fmt.Errorf("invalid page %d", page)
+		}
+		if pageSize < 1 {
+			return nil, fmt.Errorf("invalid page size %d", pageSize)
+		}
+		o.Offset = (page - 1) * pageSize
+		o.Limit = pageSize
+		return o, nil
+	}
+}
+
+func ListOptionWithOrderBy(orderBy string, ascending bool) ListOption {
+	return func(o *ListOptions) (*ListOptions, error) {
+		switch orderBy {
+		case ListOrderByKey, ListOrderByTTL:
+		default:
+			return nil, fmt.Errorf("invalid order by %q", orderBy)
+		}
+		o.OrderBy = orderBy
+		o.Ascending = ascending
+		return o, nil
+	}
+}
@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrQuotaExceeded is returned by writes exceeding the quota of a tenant
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// QuotaPolicy defines how writes exceeding the quota of a tenant are handled
+type QuotaPolicy int
+
+const (
+	// QuotaPolicyReject rejects the write with ErrQuotaExceeded
+	QuotaPolicyReject QuotaPolicy = iota
+	// QuotaPolicyEvictOldest removes the tenant's least recently written
+	// entries until the write fits
+	QuotaPolicyEvictOldest
+)
+
+// QuotaLimits limit the entries of a tenant, 0 means unlimited
+type QuotaLimits struct {
+	MaxKeys int64
+	// MaxBytes limits the sum of the stored value sizes
+	MaxBytes int64
+}
+
+// QuotaOptions configure per-tenant quotas
+type QuotaOptions struct {
+	// Default limits of all tenants
+	Default QuotaLimits
+	// Tenants overrides the limits of single tenants
+	Tenants map[string]QuotaLimits
+	Policy  QuotaPolicy
+}
+
+// TenantUsage reports the usage and limits of a tenant
+type TenantUsage struct {
+	TenantUuid string
+	Keys       int64
+	Bytes      int64
+	MaxKeys    int64
+	MaxBytes   int64
+}
+
+// quotaSetScript writes an entry enforcing the quota of its tenant. Besides
+// the tenant index, the size and write time of each entry and the sum of
+// all sizes are tracked. Expired entries are pruned before the quota is
+// checked. Returns the keys of evicted entries, which are removed from their
+// tags as well.
+//
+// KEYS: entry key, tenant index, tenant sizes, tenant bytes, tenant writes
+// ARGV: value, ttl in ms (0 means no expiration), expiration in unix ms or
+// "+inf", now in unix ms, max keys, max bytes, evict ("1" or "0"),
+// namespace, prefix of entry tags keys
+var quotaSetScript = redis.NewScript(`
+local key, index, sizes, bytes, writes = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local ttl, maxKeys, maxBytes = tonumber(ARGV[2]), tonumber(ARGV[5]), tonumber(ARGV[6])
+local size = string.len(ARGV[1])
+
+local function drop(member)
+	local memberSize = tonumber(redis.call("HGET", sizes, member) or "0")
+	redis.call("HDEL", sizes, member)
+	redis.call("ZREM", index, member)
+	redis.call("ZREM", writes, member)
+	redis.call("DECRBY", bytes, memberSize)
+end
+
+-- usage without the previous value of key, which is replaced
+local function usage()
+	local keys = redis.call("ZCARD", index)
+	local used = tonumber(redis.call("GET", bytes) or "0")
+	if redis.call("ZSCORE", index, key) then
+		keys = keys - 1
+		used = used - tonumber(redis.call("HGET", sizes, key) or "0")
+	end
+	return keys, used
+end
+
+-- least recently written entry other than key, entries written before
+-- write times were tracked are evicted by expiration
+local function oldest()
+	for _, set in ipairs({writes, index}) do
+		for _, member in ipairs(redis.call("ZRANGE", set, 0, 1)) do
+			if member ~= key then
+				return member
+			end
+		end
+	end
+end
+
+for _, member in ipairs(redis.call("ZRANGEBYSCORE", index, "-inf", "(" .. ARGV[4])) do
+	drop(member)
+end
+
+if maxBytes > 0 and size > maxBytes then
+	return redis.error_reply("QUOTA_EXCEEDED value exceeds max bytes")
+end
+local evicted = {}
+local keys, used = usage()
+while (maxKeys > 0 and keys + 1 > maxKeys) or (maxBytes > 0 and used + size > maxBytes) do
+	if ARGV[7] ~= "1" then
+		return redis.error_reply("QUOTA_EXCEEDED")
+	end
+	local member = oldest()
+	if not member then
+		return redis.error_reply("QUOTA_EXCEEDED")
+	end
+	redis.call("UNLINK", member)
+	local tagsKey = ARGV[9] .. string.sub(member, #ARGV[8] + 1)
+	for _, tagKey in ipairs(redis.call("SMEMBERS", tagsKey)) do
+		redis.call("SREM", tagKey, member)
+	end
+	redis.call("DEL", tagsKey)
+	drop(member)
+	table.insert(evicted, member)
+	keys, used = usage()
+end
+
+if ttl > 0 then
+	redis.call("SET", key, ARGV[1], "PX", ttl)
+else
+	redis.call("SET", key, ARGV[1])
+end
+if redis.call("ZSCORE", index, key) then
+	drop(key)
+end
+redis.call("ZADD", index, ARGV[3], key)
+redis.call("ZADD", writes, ARGV[4], key)
+redis.call("HSET", sizes, key, size)
+redis.call("INCRBY", bytes, size)
+
+-- usage expires with the longest living entry
+local last = redis.call("ZRANGE", index, -1, -1, "WITHSCORES")
+for _, k in ipairs({index, sizes, bytes, writes}) do
+	if last[2] == "inf" then
+		redis.call("PERSIST", k)
+	else
+		redis.call("PEXPIREAT", k, last[2])
+	end
+end
+return evicted
+`)
+
+// quotaDeleteScript removes an entry from the usage of its tenant
+//
+// KEYS: tenant index, tenant sizes, tenant bytes, tenant writes
+// ARGV: entry key
+var quotaDeleteScript = newIdempotentScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 1 then
+	redis.call("DECRBY", KEYS[3], tonumber(redis.call("HGET", KEYS[2], ARGV[1]) or "0"))
+end
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("ZREM", KEYS[4], ARGV[1])
+return 1
+`)
+
+// quotaUsageScript prunes expired entries and returns keys and bytes of a
+// tenant
+//
+// KEYS: tenant index, tenant sizes, tenant bytes, tenant writes
+// ARGV: now in unix ms
+var quotaUsageScript = newIdempotentScript(`
+for _, member in ipairs(redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[1])) do
+	redis.call("DECRBY", KEYS[3], tonumber(redis.call("HGET", KEYS[2], member) or "0"))
+	redis.call("HDEL", KEYS[2], member)
+	redis.call("ZREM", KEYS[1], member)
+	redis.call("ZREM", KEYS[4], member)
+end
+return {redis.call("ZCARD", KEYS[1]), tonumber(redis.call("GET", KEYS[3]) or "0")}
+`)
+
+// quotaTenant returns the tenant of key if its writes are subject to a quota
+func (csr *cacheStoreRedis) quotaTenant(key string) (string, bool) {
+	if csr.storeOptions.Quota == nil || csr.storeOptions.TenantResolver == nil {
+		return "", false
+	}
+	return csr.storeOptions.TenantResolver(key)
+}
+
+// quotaLimits returns the limits of the given tenant
+func (csr *cacheStoreRedis) quotaLimits(tenantUuid string) QuotaLimits {
+	if limits, ok := csr.storeOptions.Quota.Tenants[tenantUuid]; ok {
+		return limits
+	}
+	return csr.storeOptions.Quota.Default
+}
+
+// quotaKeys returns index, sizes, bytes and writes keys of the given tenant
+func (csr *cacheStoreRedis) quotaKeys(tenantUuid string) []string {
+	return []string{
+		csr.internalKey("tenant", tenantUuid),
+		csr.internalKey("sizes", tenantUuid),
+		csr.internalKey("bytes", tenantUuid),
+		csr.internalKey("writes", tenantUuid),
+	}
+}
+
+// queueQuotaSet queues writing an entry subject to the quota of tenantUuid
+func (csr *cacheStoreRedis) queueQuotaSet(ctx context.Context, pipe redis.Pipeliner, tenantUuid, key string, value []byte, expiration time.Duration) redis.Cmder {
+	now := time.Now()
+	score := "+inf"
+	if expiration > 0 {
+		score = strconv.FormatInt(now.Add(expiration).UnixMilli(), 10)
+	}
+	ttl := expiration.Milliseconds()
+	if expiration > 0 && ttl == 0 {
+		ttl = 1
+	}
+	limits := csr.quotaLimits(tenantUuid)
+	evict := "0"
+	if csr.storeOptions.Quota.Policy == QuotaPolicyEvictOldest {
+		evict = "1"
+	}
+	keys := append([]string{csr.key(key)}, csr.quotaKeys(tenantUuid)...)
+	return quotaSetScript.Eval(ctx, pipe, keys,
+		value, ttl, score, now.UnixMilli(), limits.MaxKeys, limits.MaxBytes, evict,
+		csr.storeOptions.Namespace, csr.internalKey("tags", ""),
+	)
+}
+
+// evictedKeys returns the keys evicted by a write queued using queueSet to
+// enforce the tenant quota
+func (csr *cacheStoreRedis) evictedKeys(cmd redis.Cmder) []string {
+	quotaCmd, ok := cmd.(*redis.Cmd)
+	if !ok {
+		return nil
+	}
+	redisKeys, err := quotaCmd.StringSlice()
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, len(redisKeys))
+	for i, redisKey := range redisKeys {
+		keys[i] = csr.unkey(redisKey)
+	}
+	return keys
+}
+
+// quotaError converts the error of a write to ErrQuotaExceeded if the
+// quota of the key's tenant was exceeded
+func (csr *cacheStoreRedis) quotaError(key string, err error) error {
+	// servers may prefix the error code of scripts, e.g. "ERR"
+	if err == nil || !strings.Contains(err.Error(), "QUOTA_EXCEEDED") {
+		return err
+	}
+	tenantUuid, _ := csr.quotaTenant(key)
+	return fmt.Errorf("'%s' failed - tenant '%s': %w", csr.String(), tenantUuid, ErrQuotaExceeded)
+}
+
+func (csr *cacheStoreRedis) TenantUsage(ctx context.Context, tenantUuids ...string) ([]*TenantUsage, error) {
+	if csr.storeOptions.Quota == nil {
+		return nil, fmt.Errorf("'%s' failed - tenant quotas are not enabled", csr.String())
+	}
+
+	// all tenants with tracked usage
+	if len(tenantUuids) == 0 {
+		prefix := csr.internalKey("bytes", "")
+		if err := csr.scan(ctx, csr.redisClient, escapePattern(prefix)+"*", func(keys []string) error {
+			for _, key := range keys {
+				tenantUuids = append(tenantUuids, strings.TrimPrefix(key, prefix))
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("'%s' failed - failed to scan tenant usage: %w", csr.String(), err)
+		}
+	}
+
+	pipe := csr.redisClient.Pipeline()
+	cmds := make([]*redis.Cmd, len(tenantUuids))
+	now := time.Now().UnixMilli()
+	for i, tenantUuid := range tenantUuids {
+		cmds[i] = quotaUsageScript.Eval(ctx, pipe, csr.quotaKeys(tenantUuid), now)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to get tenant usage: %w", csr.String(), err)
+	}
+	usages := make([]*TenantUsage, len(tenantUuids))
+	for i, tenantUuid := range tenantUuids {
+		values, err := cmds[i].Int64Slice()
+		if err != nil || len(values) != 2 {
+			return nil, fmt.Errorf("'%s' failed - invalid tenant usage: %v", csr.String(), err)
+		}
+		limits := csr.quotaLimits(tenantUuid)
+		usages[i] = &TenantUsage{
+			TenantUuid: tenantUuid,
+			Keys:       values[0],
+			Bytes:      values[1],
+			MaxKeys:    limits.MaxKeys,
+			MaxBytes:   limits.MaxBytes,
+		}
+	}
+	return usages, nil
+}
@@ -0,0 +1,198 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_QuotaReject(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with max 3 keys per tenant, tenant2 unlimited
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithTenantIndex(store.TenantResolverDelimiter("-")),
+		store.OptionWithTenantQuota(3, 0, store.QuotaPolicyReject),
+		store.OptionWithTenantQuotaOverride("tenant2", 0, 0),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("tenant1-key%d", i), i),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// fourth key exceeds the quota
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-key3", 3),
+	); !errors.Is(err, store.ErrQuotaExceeded) {
+		t.Fatalf("expected quota exceeded, got %v", err)
+	}
+	if results, err := cacheStore.SetMany(ctx,
+		&store.BatchItem{Key: "tenant1-key4", Value: 4},
+		&store.BatchItem{Key: "tenant2-key1", Value: 1},
+	); err != nil {
+		t.Fatal(err)
+	} else if !errors.Is(results[0].Err, store.ErrQuotaExceeded) || results[1].Err != nil {
+		t.Fatalf("unexpected results: %v, %v", results[0].Err, results[1].Err)
+	}
+
+	// rewriting an existing key is allowed
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-key0", "rewritten"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// deleting frees the quota
+	if err := cacheStore.Delete(ctx,
+		comby.CacheStoreDeleteOptionWithKey("tenant1-key1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-key3", 3),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// usage of all tenants
+	if usages, err := cacheStore.TenantUsage(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(usages) != 2 {
+		t.Fatalf("expected 2 tenants, got %d", len(usages))
+	}
+	if usages, err := cacheStore.TenantUsage(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if usages[0].Keys != 3 || usages[0].MaxKeys != 3 || usages[0].Bytes == 0 {
+		t.Fatalf("wrong usage: %+v", usages[0])
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_QuotaEvictOldest(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with max 100 bytes per tenant
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithTags(),
+		store.OptionWithTenantIndex(store.TenantResolverDelimiter("-")),
+		store.OptionWithTenantQuota(0, 100, store.QuotaPolicyEvictOldest),
+		store.OptionWithNearCache(10, time.Minute),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// each value takes about 40 bytes encoded, the first entry is tagged
+	// and never expires, later entries expire earlier
+	value := strings.Repeat("x", 20)
+	for i := 0; i < 4; i++ {
+		opts := []store.SetOption{
+			store.SetOptionWithKeyValue(fmt.Sprintf("tenant1-key%d", i), value),
+			store.SetOptionWithExpiration(time.Duration(10-i) * time.Minute),
+		}
+		if i == 0 {
+			opts = append(opts, store.SetOptionWithExpiration(0), store.SetOptionWithTags("tag1"))
+		}
+		if err := cacheStore.SetWithOptions(ctx, opts...); err != nil {
+			t.Fatal(err)
+		}
+		// read into the near cache before it is evicted
+		if cacheModel, _ := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("tenant1-key0")); i == 1 && cacheModel == nil {
+			t.Fatalf("expected entry before eviction")
+		}
+	}
+
+	// least recently written entries were evicted, regardless of expiration
+	for key, exists := range map[string]bool{"tenant1-key0": false, "tenant1-key1": false, "tenant1-key2": true, "tenant1-key3": true} {
+		if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(key)); err != nil {
+			t.Fatal(err)
+		} else if (cacheModel != nil) != exists {
+			t.Fatalf("%s: expected exists %v", key, exists)
+		}
+	}
+	count, err := cacheStore.CountByTenant(ctx, "tenant1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usages, err := cacheStore.TenantUsage(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if usages[0].Bytes > 100 || usages[0].Keys != count {
+		t.Fatalf("wrong usage: %+v, count %d", usages[0], count)
+	}
+
+	// evicted entries are removed from their tags
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 3})
+	defer redisClient.Close()
+	if keys, err := redisClient.Keys(ctx, "__comby:tag*").Result(); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 0 {
+		t.Fatalf("expected no tag keys, got %v", keys)
+	}
+
+	// values larger than the quota are rejected
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-large", strings.Repeat("x", 200)),
+	); !errors.Is(err, store.ErrQuotaExceeded) {
+		t.Fatalf("expected quota exceeded, got %v", err)
+	}
+
+	// tagged writes keep the expiration written by the quota script
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("tenant1-tagged", "tagged"),
+		store.SetOptionWithExpiration(5*time.Minute),
+		store.SetOptionWithTags("tag2"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := redisClient.PTTL(ctx, "tenant1-tagged").Val(); ttl <= 0 || ttl > 5*time.Minute {
+		t.Fatalf("wrong ttl: %v", ttl)
+	}
+	if removed, err := cacheStore.InvalidateTags(ctx, "tag2"); err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 removed entry, got %d", removed)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// quotas require the tenant index
+	cacheStore = store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithTenantQuota(10, 0, store.QuotaPolicyReject),
+	)
+	if err := cacheStore.Init(ctx); err == nil {
+		t.Fatalf("expected error without tenant index")
+	}
+}
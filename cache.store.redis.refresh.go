@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/gradientzero/comby/v2"
+)
+
+// Refresher reloads the value of a stale cache entry
+type Refresher func(ctx context.Context, key string) (any, error)
+
+// Entry is a cache entry including its soft expiration
+type Entry struct {
+	CacheModel *comby.CacheModel
+	// StaleAt is the time in UnixNano the soft TTL passes, 0 if the entry
+	// has no soft TTL
+	StaleAt int64
+	// Stale reports whether the soft TTL has passed
+	Stale bool
+
+	header *softTTLHeader
+}
+
+// softTTLMarker prefixes encoded values stored with a soft TTL
+var softTTLMarker = []byte("\x00swr")
+
+// softTTLHeaderSize is the size of marker, StoredAt, TTL and SoftTTL
+const softTTLHeaderSize = 4 + 3*8
+
+// softTTLHeader is stored in front of the encoded value of entries with a
+// soft TTL, keeping TTL to refresh the entry with its original expiration
+type softTTLHeader struct {
+	StoredAt int64
+	TTL      time.Duration
+	SoftTTL  time.Duration
+}
+
+func (h *softTTLHeader) encode(data []byte) []byte {
+	buf := make([]byte, softTTLHeaderSize, softTTLHeaderSize+len(data))
+	copy(buf, softTTLMarker)
+	binary.BigEndian.PutUint64(buf[4:], uint64(h.StoredAt))
+	binary.BigEndian.PutUint64(buf[12:], uint64(h.TTL))
+	binary.BigEndian.PutUint64(buf[20:], uint64(h.SoftTTL))
+	return append(buf, data...)
+}
+
+// staleAt returns the time in UnixNano the soft TTL passes
+func (h *softTTLHeader) staleAt() int64 {
+	return h.StoredAt + int64(h.SoftTTL)
+}
+
+// decodeSoftTTLHeader splits data into header and encoded value, the header
+// is nil if data has none
+func decodeSoftTTLHeader(data []byte) ([]byte, *softTTLHeader) {
+	if len(data) < softTTLHeaderSize || string(data[:4]) != string(softTTLMarker) {
+		return data, nil
+	}
+	return data[softTTLHeaderSize:], &softTTLHeader{
+		StoredAt: int64(binary.BigEndian.Uint64(data[4:])),
+		TTL:      time.Duration(binary.BigEndian.Uint64(data[12:])),
+		SoftTTL:  time.Duration(binary.BigEndian.Uint64(data[20:])),
+	}
+}
+
+func (csr *cacheStoreRedis) GetEntry(ctx context.Context, key string) (*Entry, error) {
+	var generation uint64
+	if csr.nearCache != nil {
+		if entry, ok := csr.nearCache.get(key); ok {
+			if csr.refreshDue(entry) {
+				csr.refresh(key, entry.header)
+			}
+			return entry, nil
+		}
+		generation = csr.nearCache.currentGeneration()
+	}
+	entry, err := csr.getEntry(ctx, csr.key(key), key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		csr.redisMisses.Add(1)
+		return nil, nil
+	}
+	csr.redisHits.Add(1)
+	if csr.nearCache != nil {
+		csr.nearCache.add(key, entry, generation)
+	}
+	if csr.refreshDue(entry) {
+		csr.refresh(key, entry.header)
+	}
+	return entry, nil
+}
+
+// refreshDue reports whether entry is stale or, using refresh ahead, close
+// to become stale
+func (csr *cacheStoreRedis) refreshDue(entry *Entry) bool {
+	if entry.header == nil || csr.storeOptions.Refresher == nil {
+		return false
+	}
+	if entry.Stale {
+		return true
+	}
+	if ratio := csr.storeOptions.RefreshAhead; ratio > 0 {
+		refreshAt := entry.header.StoredAt + int64(float64(entry.header.SoftTTL)*ratio)
+		return time.Now().UnixNano() >= refreshAt
+	}
+	return false
+}
+
+// refresh reloads the entry of key in background using the Refresher and
+// stores it with its original TTL, soft TTL and tags. Refreshes of the same
+// key are coalesced within the process and, by a short Redis lock, across
+// instances.
+func (csr *cacheStoreRedis) refresh(key string, header *softTTLHeader) {
+	if _, running := csr.refreshing.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	csr.refreshWG.Add(1)
+	go func() {
+		defer csr.refreshWG.Done()
+		defer csr.refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.Background(), csr.storeOptions.RefreshTimeout)
+		defer cancel()
+
+		lockKey := csr.internalKey("refresh", key)
+		token, err := lockToken()
+		if err != nil {
+			return
+		}
+		if acquired, err := csr.redisClient.SetNX(ctx, lockKey, token, csr.storeOptions.RefreshTimeout).Result(); err != nil || !acquired {
+			return
+		}
+		defer releaseLockScript.Run(context.WithoutCancel(ctx), csr.redisClient, []string{lockKey}, token)
+
+		value, err := csr.storeOptions.Refresher(ctx, key)
+		if err != nil {
+			// keep serving the stale value until it expires
+			return
+		}
+		// the refreshed entry keeps its tags
+		tags, err := csr.entryTags(ctx, key)
+		if err != nil {
+			return
+		}
+		csr.setWithOptions(ctx, &SetOptions{
+			Key:        key,
+			Value:      value,
+			Expiration: header.TTL,
+			SoftTTL:    header.SoftTTL,
+			Tags:       tags,
+		})
+	}()
+}
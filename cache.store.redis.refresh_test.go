@@ -0,0 +1,153 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_StaleWhileRevalidate(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with refresher
+	var refreshes atomic.Int32
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithRefresher(func(ctx context.Context, key string) (any, error) {
+			return fmt.Sprintf("refreshed%d", refreshes.Add(1)), nil
+		}, time.Second),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set a value with soft TTL
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("swrKey", "initial"),
+		store.SetOptionWithExpiration(time.Minute),
+		store.SetOptionWithSoftTTL(100*time.Millisecond),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// fresh entry
+	if entry, err := cacheStore.GetEntry(ctx, "swrKey"); err != nil {
+		t.Fatal(err)
+	} else if entry.Stale || entry.StaleAt == 0 || entry.CacheModel.Value != "initial" {
+		t.Fatalf("expected fresh entry, got %+v", entry)
+	}
+
+	// stale entry is returned and refreshed in background
+	time.Sleep(150 * time.Millisecond)
+	if entry, err := cacheStore.GetEntry(ctx, "swrKey"); err != nil {
+		t.Fatal(err)
+	} else if !entry.Stale || entry.CacheModel.Value != "initial" {
+		t.Fatalf("expected stale entry, got %+v", entry)
+	}
+	var entry *store.Entry
+	for i := 0; i < 50; i++ {
+		if entry, err = cacheStore.GetEntry(ctx, "swrKey"); err != nil {
+			t.Fatal(err)
+		} else if entry.CacheModel.Value != "initial" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if entry.Stale || entry.CacheModel.Value == "initial" {
+		t.Fatalf("expected refreshed entry, got %+v", entry)
+	}
+
+	// refresh keeps the hard TTL
+	if entry.CacheModel.ExpiredAt < time.Now().Add(50*time.Second).UnixNano() {
+		t.Fatalf("wrong expiration %d", entry.CacheModel.ExpiredAt)
+	}
+
+	// values without soft TTL are never stale
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("hardKey", "value"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := cacheStore.GetEntry(ctx, "hardKey"); err != nil {
+		t.Fatal(err)
+	} else if entry.Stale || entry.StaleAt != 0 {
+		t.Fatalf("expected entry without soft TTL, got %+v", entry)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_RefreshAhead(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with default soft TTL and refresh ahead
+	var refreshes atomic.Int32
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithSoftTTL(200*time.Millisecond),
+		store.OptionWithRefreshAhead(0.5),
+		store.OptionWithRefresher(func(ctx context.Context, key string) (any, error) {
+			refreshes.Add(1)
+			return "refreshed", nil
+		}, time.Second),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("aheadKey", "initial"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// entry is refreshed before it becomes stale
+	time.Sleep(120 * time.Millisecond)
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("aheadKey"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "initial" {
+		t.Fatalf("wrong value: %v", cacheModel.Value)
+	}
+
+	// close waits for the background refresh
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+	if refreshes.Load() != 1 {
+		t.Fatalf("expected 1 refresh, got %d", refreshes.Load())
+	}
+
+	// invalid options
+	tests := map[string]store.Option{
+		"negative soft ttl":   store.OptionWithSoftTTL(-time.Second),
+		"nil refresher":       store.OptionWithRefresher(nil, time.Second),
+		"invalid timeout":     store.OptionWithRefresher(func(ctx context.Context, key string) (any, error) { return nil, nil }, 0),
+		"invalid ahead ratio": store.OptionWithRefreshAhead(1.5),
+	}
+	for name, opt := range tests {
+		if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"}, opt); cacheStore != nil {
+			t.Fatalf("%s: expected nil when option fails", name)
+		}
+	}
+}
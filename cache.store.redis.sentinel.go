@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewCacheStoreRedisSentinel creates a cache store backed by a Redis master
+// monitored by Redis Sentinel. Failovers are handled transparently by
+// reconnecting to the newly elected master.
+func NewCacheStoreRedisSentinel(
+	failoverOptions *redis.FailoverOptions,
+	opts ...Option,
+) CacheStoreRedis {
+	csr, err := newCacheStoreRedis(opts...)
+	if err != nil {
+		return nil
+	}
+	csr.failoverOptions = failoverOptions
+	return csr
+}
+
+// masterAddr asks the sentinels for the address of the current master
+func (csr *cacheStoreRedis) masterAddr(ctx context.Context) (string, error) {
+	// sentinels are connected like the failover client does
+	tlsConfig := csr.failoverOptions.TLSConfig
+	if tlsOptions := csr.storeOptions.TLS; tlsOptions != nil {
+		tlsConfig = tlsOptions.tlsConfig(tlsConfig)
+	}
+	lastErr := fmt.Errorf("no sentinel addresses")
+	for _, sentinelAddr := range csr.failoverOptions.SentinelAddrs {
+		sentinel := redis.NewSentinelClient(&redis.Options{
+			Addr:      sentinelAddr,
+			Username:  csr.failoverOptions.SentinelUsername,
+			Password:  csr.failoverOptions.SentinelPassword,
+			TLSConfig: tlsConfig,
+		})
+		addr, err := sentinel.GetMasterAddrByName(ctx, csr.failoverOptions.MasterName).Result()
+		sentinel.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(addr) != 2 {
+			lastErr = fmt.Errorf("unexpected reply %v", addr)
+			continue
+		}
+		return net.JoinHostPort(addr[0], addr[1]), nil
+	}
+	return "", fmt.Errorf("'%s' failed - failed to get master address: %w", csr.String(), lastErr)
+}
@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// sentinelOptions returns the failover options of a running Redis Sentinel
+// setup, e.g. REDIS_SENTINEL_ADDRS=localhost:26379 REDIS_SENTINEL_MASTER=mymaster
+func sentinelOptions(t *testing.T) *redis.FailoverOptions {
+	addrs := os.Getenv("REDIS_SENTINEL_ADDRS")
+	if len(addrs) == 0 {
+		t.Skip("REDIS_SENTINEL_ADDRS not set")
+	}
+	masterName := os.Getenv("REDIS_SENTINEL_MASTER")
+	if len(masterName) == 0 {
+		masterName = "mymaster"
+	}
+	return &redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    strings.Split(addrs, ","),
+		SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+	}
+}
+
+func TestCacheStoreSentinel(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store reading from replicas
+	cacheStore := store.NewCacheStoreRedisSentinel(sentinelOptions(t),
+		store.OptionWithReadFromReplicas(),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set a value on master
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("sentinelKey", "sentinelValue"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// Info reports the current master
+	if info, err := cacheStore.Info(ctx); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(info.ConnectionInfo, "master: ") {
+		t.Fatalf("expected master in ConnectionInfo, got %q", info.ConnectionInfo)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_ReadFromReplicasRequiresReplicas(t *testing.T) {
+	ctx := context.Background()
+
+	// a single node has no replicas to read from
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithReadFromReplicas(),
+	)
+	if err := cacheStore.Init(ctx); err == nil {
+		t.Fatalf("expected error when reading from replicas of a single node")
+	}
+}
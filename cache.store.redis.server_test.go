@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// lookupRedisServer returns the path of redis-server, the test is skipped if
+// it is not found in PATH
+func lookupRedisServer(t *testing.T) string {
+	redisServer, err := exec.LookPath("redis-server")
+	if err != nil {
+		t.Skip("redis-server not found in PATH")
+	}
+	return redisServer
+}
+
+// runRedisServer starts a local redis-server listening on a free port passed
+// using portFlag, e.g. "--tls-port", and stops it when the test ends. It
+// returns the address once the server accepts connections, ok is false if it
+// did not in time.
+func runRedisServer(t *testing.T, portFlag string, args ...string) (addr string, ok bool) {
+	redisServer := lookupRedisServer(t)
+
+	// find a free port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	args = append([]string{
+		portFlag, fmt.Sprint(port),
+		"--bind", "127.0.0.1",
+		"--save", "",
+		"--appendonly", "no",
+	}, args...)
+	cmd := exec.Command(redisServer, args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	// wait until the server accepts connections
+	addr = fmt.Sprintf("127.0.0.1:%d", port)
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return addr, true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return "", false
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagPruneSample is the number of random members of a tag checked for
+// expired entries on each tagged write
+const tagPruneSample = 10
+
+// setTaggedScript writes an entry and maintains the tag index. Each tag is a
+// set of entry keys, each entry keeps the set of its tag keys to be removed
+// from them when rewritten. Tags expire with their longest living entry,
+// members of expired entries are pruned by sampling. The value is not
+// written if already written by the quota script.
+//
+// KEYS: entry key, entry tags key, tag keys...
+// ARGV: value, ttl in ms (0 means no expiration), prune sample, write value
+// ("1" or "0")
+var setTaggedScript = newIdempotentScript(`
+local key, tagsKey = KEYS[1], KEYS[2]
+local ttl = tonumber(ARGV[2])
+
+-- remove entry from the tags of a previous write
+for _, tagKey in ipairs(redis.call("SMEMBERS", tagsKey)) do
+	redis.call("SREM", tagKey, key)
+end
+redis.call("DEL", tagsKey)
+
+if ARGV[4] == "1" then
+	if ttl > 0 then
+		redis.call("SET", key, ARGV[1], "PX", ttl)
+	else
+		redis.call("SET", key, ARGV[1])
+	end
+end
+
+for i = 3, #KEYS do
+	local tagKey = KEYS[i]
+	local tagTTL = redis.call("PTTL", tagKey)
+	redis.call("SADD", tagKey, key)
+	redis.call("SADD", tagsKey, tagKey)
+	if ttl <= 0 then
+		redis.call("PERSIST", tagKey)
+	elseif tagTTL == -2 or (tagTTL >= 0 and tagTTL < ttl) then
+		redis.call("PEXPIRE", tagKey, ttl)
+	end
+	for _, member in ipairs(redis.call("SRANDMEMBER", tagKey, ARGV[3])) do
+		if redis.call("EXISTS", member) == 0 then
+			redis.call("SREM", tagKey, member)
+		end
+	end
+end
+if ttl > 0 then
+	redis.call("PEXPIRE", tagsKey, ttl)
+end
+return 1
+`)
+
+// invalidateTagsScript deletes all entries of the given tags along with
+// their tag index and returns the keys of the deleted entries.
+//
+// KEYS: tag keys...
+// ARGV: namespace, prefix of entry tags keys
+var invalidateTagsScript = redis.NewScript(`
+local removed = {}
+for _, tagKey in ipairs(KEYS) do
+	for _, key in ipairs(redis.call("SMEMBERS", tagKey)) do
+		local tagsKey = ARGV[2] .. string.sub(key, #ARGV[1] + 1)
+		for _, otherTagKey in ipairs(redis.call("SMEMBERS", tagsKey)) do
+			if otherTagKey ~= tagKey then
+				redis.call("SREM", otherTagKey, key)
+			end
+		end
+		redis.call("DEL", tagsKey)
+		if redis.call("UNLINK", key) == 1 then
+			table.insert(removed, key)
+		end
+	end
+	redis.call("DEL", tagKey)
+end
+return removed
+`)
+
+// untagScript removes entries from their tags, e.g. when deleted or
+// rewritten without tags
+//
+// KEYS: entry tags keys...
+// ARGV: entry keys in the order of KEYS
+var untagScript = newIdempotentScript(`
+for i, tagsKey in ipairs(KEYS) do
+	for _, tagKey in ipairs(redis.call("SMEMBERS", tagsKey)) do
+		redis.call("SREM", tagKey, ARGV[i])
+	end
+	redis.call("DEL", tagsKey)
+end
+return 1
+`)
+
+// untag removes the given keys from their tags if tags are enabled
+func (csr *cacheStoreRedis) untag(ctx context.Context, keys ...string) error {
+	if !csr.storeOptions.Tags || len(keys) == 0 {
+		return nil
+	}
+	tagsKeys := make([]string, len(keys))
+	redisKeys := make([]any, len(keys))
+	for i, key := range keys {
+		tagsKeys[i] = csr.internalKey("tags", key)
+		redisKeys[i] = csr.key(key)
+	}
+	return untagScript.Run(ctx, csr.redisClient, tagsKeys, redisKeys...).Err()
+}
+
+// entryTags returns the tags of the entry of key
+func (csr *cacheStoreRedis) entryTags(ctx context.Context, key string) ([]string, error) {
+	if !csr.storeOptions.Tags {
+		return nil, nil
+	}
+	tagKeys, err := csr.redisClient.SMembers(ctx, csr.internalKey("tags", key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	prefix := csr.internalKey("tag", "")
+	tags := make([]string, len(tagKeys))
+	for i, tagKey := range tagKeys {
+		tags[i] = strings.TrimPrefix(tagKey, prefix)
+	}
+	return tags, nil
+}
+
+func (csr *cacheStoreRedis) setTagged(ctx context.Context, setOpts *SetOptions) error {
+	if csr.clusterOptions != nil {
+		return fmt.Errorf("'%s' failed - tags are not supported on Redis Cluster", csr.String())
+	}
+	if !csr.storeOptions.Tags {
+		return fmt.Errorf("'%s' failed - tags are not enabled, see OptionWithTags", csr.String())
+	}
+	valueToStore, err := csr.encodeEntry(setOpts.Key, setOpts.Value, setOpts.Expiration, setOpts.SoftTTL)
+	if err != nil {
+		return err
+	}
+	// enforce the tenant quota, the quota script writes the value and the
+	// tagged write maintains the tags only
+	_, hasQuota := csr.quotaTenant(setOpts.Key)
+	if hasQuota {
+		pipe := csr.redisClient.Pipeline()
+		setCmd := csr.queueSet(ctx, pipe, setOpts.Key, valueToStore, setOpts.Expiration)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return csr.quotaError(setOpts.Key, err)
+		}
+		if err := setCmd.Err(); err != nil {
+			return err
+		}
+		csr.invalidate(ctx, csr.evictedKeys(setCmd)...)
+	}
+
+	keys := []string{csr.key(setOpts.Key), csr.internalKey("tags", setOpts.Key)}
+	for _, tag := range setOpts.Tags {
+		keys = append(keys, csr.internalKey("tag", tag))
+	}
+	ttl := setOpts.Expiration.Milliseconds()
+	if setOpts.Expiration > 0 && ttl == 0 {
+		ttl = 1
+	}
+	writeValue := "1"
+	if hasQuota {
+		writeValue = "0"
+	}
+	if err := setTaggedScript.Run(ctx, csr.redisClient, keys,
+		valueToStore, ttl, tagPruneSample, writeValue,
+	).Err(); err != nil {
+		return err
+	}
+	csr.invalidate(ctx, setOpts.Key)
+	if _, ok := csr.tenantIndexKey(setOpts.Key); !ok || hasQuota {
+		return nil
+	}
+	pipe := csr.redisClient.Pipeline()
+	csr.indexTenant(ctx, pipe, setOpts.Key, setOpts.Expiration)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (csr *cacheStoreRedis) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	if csr.clusterOptions != nil {
+		return 0, fmt.Errorf("'%s' failed - tags are not supported on Redis Cluster", csr.String())
+	}
+	if !csr.storeOptions.Tags {
+		return 0, fmt.Errorf("'%s' failed - tags are not enabled, see OptionWithTags", csr.String())
+	}
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = csr.internalKey("tag", tag)
+	}
+	removed, err := invalidateTagsScript.Run(ctx, csr.redisClient, keys,
+		csr.storeOptions.Namespace, csr.internalKey("tags", ""),
+	).StringSlice()
+	if err != nil {
+		return 0, fmt.Errorf("'%s' failed - failed to invalidate tags: %w", csr.String(), err)
+	}
+	removedKeys := make([]string, len(removed))
+	for i, key := range removed {
+		removedKeys[i] = csr.unkey(key)
+	}
+	csr.invalidate(ctx, removedKeys...)
+
+	// remove deleted entries from the tenant index
+	if csr.storeOptions.TenantResolver != nil && len(removed) > 0 {
+		pipe := csr.redisClient.Pipeline()
+		csr.unindexTenant(ctx, pipe, removedKeys...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return int64(len(removed)), fmt.Errorf("'%s' failed - failed to update tenant index: %w", csr.String(), err)
+		}
+	}
+	return int64(len(removed)), nil
+}
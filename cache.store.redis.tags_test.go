@@ -0,0 +1,282 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheStore_InvalidateTags(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithTags(),
+		store.OptionWithNamespace("app:"),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set tagged values
+	items := map[string][]string{
+		"view1": {"order:1"},
+		"view2": {"order:1", "customer:1"},
+		"view3": {"customer:1"},
+		"view4": {"order:2"},
+	}
+	for key, tags := range items {
+		if err := cacheStore.SetWithOptions(ctx,
+			store.SetOptionWithKeyValue(key, key),
+			store.SetOptionWithExpiration(time.Minute),
+			store.SetOptionWithTags(tags...),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// tag index is not listed
+	if _, total, err := cacheStore.List(ctx); err != nil {
+		t.Fatal(err)
+	} else if total != 4 {
+		t.Fatalf("expected total 4, got %d", total)
+	}
+
+	// invalidate all entries of order 1
+	if removed, err := cacheStore.InvalidateTags(ctx, "order:1"); err != nil {
+		t.Fatal(err)
+	} else if removed != 2 {
+		t.Fatalf("expected 2 removed entries, got %d", removed)
+	}
+	for key, exists := range map[string]bool{"view1": false, "view2": false, "view3": true, "view4": true} {
+		if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(key)); err != nil {
+			t.Fatal(err)
+		} else if (cacheModel != nil) != exists {
+			t.Fatalf("%s: expected exists %v", key, exists)
+		}
+	}
+
+	// rewriting an entry replaces its tags
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("view4", "view4"),
+		store.SetOptionWithTags("customer:2"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := cacheStore.InvalidateTags(ctx, "order:2"); err != nil {
+		t.Fatal(err)
+	} else if removed != 0 {
+		t.Fatalf("expected 0 removed entries, got %d", removed)
+	}
+
+	// invalidate multiple tags, unknown tags are ignored
+	if removed, err := cacheStore.InvalidateTags(ctx, "customer:1", "customer:2", "unknown"); err != nil {
+		t.Fatal(err)
+	} else if removed != 2 {
+		t.Fatalf("expected 2 removed entries, got %d", removed)
+	}
+
+	// tag index is cleaned up
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 1})
+	defer redisClient.Close()
+	if keys, err := redisClient.Keys(ctx, "app:*").Result(); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 0 {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+
+	// tag expires with its entries
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("short", "short"),
+		store.SetOptionWithExpiration(100*time.Millisecond),
+		store.SetOptionWithTags("expiring"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if keys, err := redisClient.Keys(ctx, "app:*").Result(); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 0 {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+
+	// rewriting an entry without tags removes it from its tags
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("view5", "view5"),
+		store.SetOptionWithTags("order:3"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue("view5", "untagged")); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := cacheStore.InvalidateTags(ctx, "order:3"); err != nil {
+		t.Fatal(err)
+	} else if removed != 0 {
+		t.Fatalf("expected 0 removed entries, got %d", removed)
+	}
+
+	// deleting entries removes them from their tags
+	for _, key := range []string{"view6", "view7"} {
+		if err := cacheStore.SetWithOptions(ctx,
+			store.SetOptionWithKeyValue(key, key),
+			store.SetOptionWithExpiration(0),
+			store.SetOptionWithTags("order:4"),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cacheStore.Delete(ctx, comby.CacheStoreDeleteOptionWithKey("view6")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.DeleteMany(ctx, "view5", "view7"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := redisClient.Keys(ctx, "app:*").Result(); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 0 {
+		t.Fatalf("expected no keys, got %v", keys)
+	}
+
+	// empty tags are rejected
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("key", "value"),
+		store.SetOptionWithTags(""),
+	); err == nil {
+		t.Fatalf("expected error with empty tag")
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// tags require the option
+	cacheStore = store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1})
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("key", "value"),
+		store.SetOptionWithTags("tag"),
+	); err == nil {
+		t.Fatalf("expected error without tags enabled")
+	}
+	if _, err := cacheStore.InvalidateTags(ctx, "tag"); err == nil {
+		t.Fatalf("expected error without tags enabled")
+	}
+	cacheStore.Close(ctx)
+}
+
+func TestCacheStore_RefreshKeepsTags(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with refresher
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 1},
+		store.OptionWithTags(),
+		store.OptionWithRefresher(func(ctx context.Context, key string) (any, error) {
+			return "refreshed", nil
+		}, time.Second),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set a tagged value with soft TTL
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("view", "initial"),
+		store.SetOptionWithExpiration(time.Minute),
+		store.SetOptionWithSoftTTL(50*time.Millisecond),
+		store.SetOptionWithTags("order:1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// stale entry is refreshed in background
+	time.Sleep(100 * time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entry, err := cacheStore.GetEntry(ctx, "view")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.CacheModel.Value == "refreshed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected refreshed entry, got %+v", entry)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// refreshed entry is still tagged
+	if removed, err := cacheStore.InvalidateTags(ctx, "order:1"); err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 removed entry, got %d", removed)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_TotalWithoutInternalKeys(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store without namespace maintaining internal keys
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 2},
+		store.OptionWithTags(),
+		store.OptionWithTenantIndex(store.TenantResolverDelimiter("-")),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"tenant1-key1", "tenant1-key2"} {
+		if err := cacheStore.SetWithOptions(ctx,
+			store.SetOptionWithKeyValue(key, key),
+			store.SetOptionWithTags("tag1", "tag2"),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// internal keys are not counted
+	if total := cacheStore.Total(ctx); total != 2 {
+		t.Fatalf("expected total 2, got %d", total)
+	}
+	if info, err := cacheStore.Info(ctx); err != nil {
+		t.Fatal(err)
+	} else if info.NumItems != 2 {
+		t.Fatalf("expected 2 items, got %d", info.NumItems)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TenantResolver returns the tenant of a cache key, ok is false if the key
+// does not belong to a tenant
+type TenantResolver func(key string) (tenantUuid string, ok bool)
+
+// TenantResolverDelimiter resolves the tenant as the part of the key before
+// the first delimiter, e.g. "tenant1" of "tenant1-key" using "-"
+func TenantResolverDelimiter(delimiter string) TenantResolver {
+	return func(key string) (string, bool) {
+		tenantUuid, _, ok := strings.Cut(key, delimiter)
+		if !ok || len(tenantUuid) == 0 {
+			return "", false
+		}
+		return tenantUuid, true
+	}
+}
+
+// TenantResolverUUIDPrefix resolves the tenant of keys starting with a
+// canonical UUID followed by "-", e.g.
+// "8b2d3c1a-2f4e-4a6b-9c8d-0e1f2a3b4c5d-key". UUIDs contain the delimiter
+// themselves, so the first "-" can not be used.
+func TenantResolverUUIDPrefix() TenantResolver {
+	return func(key string) (string, bool) {
+		if len(key) < 37 || key[36] != '-' || !isUUID(key[:36]) {
+			return "", false
+		}
+		return key[:36], true
+	}
+}
+
+// isUUID reports whether s is a UUID in canonical 8-4-4-4-12 form
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// tenantIndexScript adds an entry to the index of its tenant, a sorted set
+// of entry keys scored by expiration. Expired entries are removed and the
+// index expires with its longest living entry.
+//
+// KEYS: tenant index
+// ARGV: entry key, expiration in unix ms or "+inf", now in unix ms
+var tenantIndexScript = newIdempotentScript(`
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", "(" .. ARGV[3])
+local last = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+if last[2] == "inf" then
+	redis.call("PERSIST", KEYS[1])
+else
+	redis.call("PEXPIREAT", KEYS[1], last[2])
+end
+return 1
+`)
+
+// tenantIndexKey returns the index key of the tenant of key, or the index of
+// keys whose tenant can not be resolved. ok is false if the index is
+// disabled.
+func (csr *cacheStoreRedis) tenantIndexKey(key string) (string, bool) {
+	if csr.storeOptions.TenantResolver == nil {
+		return "", false
+	}
+	tenantUuid, ok := csr.storeOptions.TenantResolver(key)
+	if !ok {
+		return csr.unresolvedIndexKey(), true
+	}
+	return csr.internalKey("tenant", tenantUuid), true
+}
+
+// unresolvedIndexKey returns the index of keys whose tenant can not be
+// resolved, listed by the convention "tenantUuid-key" instead
+func (csr *cacheStoreRedis) unresolvedIndexKey() string {
+	return csr.internalKey("unresolved", "tenant")
+}
+
+// indexTenant queues adding key with the given expiration to the index of
+// its tenant
+func (csr *cacheStoreRedis) indexTenant(ctx context.Context, pipe redis.Pipeliner, key string, expiration time.Duration) {
+	indexKey, ok := csr.tenantIndexKey(key)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	score := "+inf"
+	if expiration > 0 {
+		score = strconv.FormatInt(now.Add(expiration).UnixMilli(), 10)
+	}
+	tenantIndexScript.Eval(ctx, pipe, []string{indexKey}, csr.key(key), score, now.UnixMilli())
+}
+
+// unindexTenant queues removing keys from the index of their tenants
+func (csr *cacheStoreRedis) unindexTenant(ctx context.Context, pipe redis.Pipeliner, keys ...string) {
+	for _, key := range keys {
+		if tenantUuid, ok := csr.quotaTenant(key); ok {
+			quotaDeleteScript.Eval(ctx, pipe, csr.quotaKeys(tenantUuid), csr.key(key))
+		} else if indexKey, ok := csr.tenantIndexKey(key); ok {
+			pipe.ZRem(ctx, indexKey, csr.key(key))
+		}
+	}
+}
+
+// tenantKeys returns the redis keys of the unexpired entries of the tenant
+// from its index, including unresolved keys prefixed "tenantUuid-"
+func (csr *cacheStoreRedis) tenantKeys(ctx context.Context, tenantUuid string) ([]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	keys, err := csr.readClient.ZRangeByScore(ctx, csr.internalKey("tenant", tenantUuid), &redis.ZRangeBy{
+		Min: now,
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("'%s' failed - failed to read tenant index: %w", csr.String(), err)
+	}
+	unresolvedKeys, err := csr.unresolvedKeys(ctx, tenantUuid)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, unresolvedKeys...), nil
+}
+
+// tenantPattern returns the pattern matching the keys of a tenant by the
+// convention "tenantUuid-key", the delimiter prevents matching tenants
+// sharing a prefix
+func (csr *cacheStoreRedis) tenantPattern(tenantUuid string) string {
+	return escapePattern(csr.key(tenantUuid+"-")) + "*"
+}
+
+// unresolvedKeys scans the index of unresolved keys for the unexpired
+// entries prefixed "tenantUuid-"
+func (csr *cacheStoreRedis) unresolvedKeys(ctx context.Context, tenantUuid string) ([]string, error) {
+	now := float64(time.Now().UnixMilli())
+	match := csr.tenantPattern(tenantUuid)
+	var keys []string
+	var cursor uint64
+	for {
+		members, nextCursor, err := csr.readClient.ZScan(ctx, csr.unresolvedIndexKey(), cursor, match, csr.storeOptions.ScanCount).Result()
+		if err != nil {
+			return nil, fmt.Errorf("'%s' failed - failed to scan unresolved tenant index: %w", csr.String(), err)
+		}
+		// members alternate with their score
+		for i := 0; i+1 < len(members); i += 2 {
+			if score, err := strconv.ParseFloat(members[i+1], 64); err == nil && score >= now {
+				keys = append(keys, members[i])
+			}
+		}
+		if nextCursor == 0 {
+			return keys, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (csr *cacheStoreRedis) CountByTenant(ctx context.Context, tenantUuid string) (int64, error) {
+	if len(tenantUuid) == 0 {
+		return 0, fmt.Errorf("'%s' failed - tenantUuid is empty", csr.String())
+	}
+	if csr.storeOptions.TenantResolver != nil {
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		total, err := csr.readClient.ZCount(ctx, csr.internalKey("tenant", tenantUuid), now, "+inf").Result()
+		if err != nil {
+			return 0, err
+		}
+		unresolvedKeys, err := csr.unresolvedKeys(ctx, tenantUuid)
+		if err != nil {
+			return 0, err
+		}
+		return total + int64(len(unresolvedKeys)), nil
+	}
+
+	// convention: prefix of key is the tenantUuid "%s-%s"
+	var total int64
+	if err := csr.scan(ctx, csr.readClient, csr.tenantPattern(tenantUuid), func(keys []string) error {
+		total += int64(len(keys))
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,270 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTenantResolver(t *testing.T) {
+	tenantUuid := "8b2d3c1a-2f4e-4a6b-9c8d-0e1f2a3b4c5d"
+	tests := []struct {
+		resolver store.TenantResolver
+		key      string
+		tenant   string
+		ok       bool
+	}{
+		{store.TenantResolverUUIDPrefix(), tenantUuid + "-key", tenantUuid, true},
+		{store.TenantResolverUUIDPrefix(), tenantUuid + "-a-b", tenantUuid, true},
+		{store.TenantResolverUUIDPrefix(), tenantUuid, "", false},
+		{store.TenantResolverUUIDPrefix(), "8b2d3c1a-key", "", false},
+		{store.TenantResolverUUIDPrefix(), "xb2d3c1a-2f4e-4a6b-9c8d-0e1f2a3b4c5d-key", "", false},
+		{store.TenantResolverDelimiter("-"), "abc-key", "abc", true},
+		{store.TenantResolverDelimiter("-"), "abcd-key-1", "abcd", true},
+		{store.TenantResolverDelimiter("-"), "abc", "", false},
+		{store.TenantResolverDelimiter(":"), "abc:key", "abc", true},
+	}
+	for _, test := range tests {
+		tenant, ok := test.resolver(test.key)
+		if tenant != test.tenant || ok != test.ok {
+			t.Fatalf("%s: expected (%q, %v), got (%q, %v)", test.key, test.tenant, test.ok, tenant, ok)
+		}
+	}
+}
+
+func TestCacheStore_TenantIndex(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithTags(),
+		store.OptionWithNamespace("cache:"),
+		store.OptionWithTenantIndex(store.TenantResolverDelimiter("-")),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set values of tenants sharing a prefix
+	for _, key := range []string{"abc-key1", "abc-key2", "abcd-key1"} {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(key, key),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cacheStore.SetMany(ctx,
+		&store.BatchItem{Key: "abc-key3", Value: "abc-key3"},
+		&store.BatchItem{Key: "abc-short", Value: "abc-short", Expiration: 100 * time.Millisecond},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// List and count only the tenant's entries
+	if cacheModels, total, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("abc"),
+	); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 4 || total != 4 {
+		t.Fatalf("expected 4 entries, got %d (total %d)", len(cacheModels), total)
+	}
+	if count, err := cacheStore.CountByTenant(ctx, "abcd"); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+
+	// expired entries are not counted
+	time.Sleep(200 * time.Millisecond)
+	if count, err := cacheStore.CountByTenant(ctx, "abc"); err != nil {
+		t.Fatal(err)
+	} else if count != 3 {
+		t.Fatalf("expected 3 entries, got %d", count)
+	}
+
+	// deleted entries are removed from the index
+	if err := cacheStore.Delete(ctx,
+		comby.CacheStoreDeleteOptionWithKey("abc-key1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cacheStore.DeleteMany(ctx, "abc-key2"); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := cacheStore.CountByTenant(ctx, "abc"); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+
+	// invalidated entries are removed from the index
+	if err := cacheStore.SetWithOptions(ctx,
+		store.SetOptionWithKeyValue("abcd-tagged", "tagged"),
+		store.SetOptionWithTags("tag1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "abcd"); count != 2 {
+		t.Fatalf("expected 2 entries, got %d", count)
+	}
+	if _, err := cacheStore.InvalidateTags(ctx, "tag1"); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "abcd"); count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+
+	// delete tenant removes its index
+	if removed, err := cacheStore.DeleteByTenant(ctx, "abc"); err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 removed entry, got %d", removed)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "abc"); count != 0 {
+		t.Fatalf("expected 0 entries, got %d", count)
+	}
+	if total := cacheStore.Total(ctx); total != 1 {
+		t.Fatalf("expected total 1, got %d", total)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_TenantIndexUnresolved(t *testing.T) {
+	var err error
+	ctx := context.Background()
+	tenantUuid := "8b2d3c1a-2f4e-4a6b-9c8d-0e1f2a3b4c5d"
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithTenantIndex(store.TenantResolverUUIDPrefix()),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set values of resolved and unresolved tenants and without tenant
+	for _, key := range []string{tenantUuid + "-key", "tenant1-key1", "tenant1-key2", "tenant10-key", "global"} {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(key, key),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// unresolved keys are listed and counted by prefix
+	if cacheModels, total, err := cacheStore.ListWithOptions(ctx,
+		store.ListOptionWithTenantUuid("tenant1"),
+	); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 2 || total != 2 {
+		t.Fatalf("expected 2 entries, got %d (total %d)", len(cacheModels), total)
+	}
+	if count, err := cacheStore.CountByTenant(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if count != 2 {
+		t.Fatalf("expected 2 entries, got %d", count)
+	}
+	if count, err := cacheStore.CountByTenant(ctx, tenantUuid); err != nil {
+		t.Fatal(err)
+	} else if count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+
+	// deleted entries are removed from the index
+	if err := cacheStore.Delete(ctx,
+		comby.CacheStoreDeleteOptionWithKey("tenant1-key1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "tenant1"); count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+	if removed, err := cacheStore.DeleteByTenant(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 removed entry, got %d", removed)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "tenant1"); count != 0 {
+		t.Fatalf("expected 0 entries, got %d", count)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "tenant10"); count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_DeleteByTenantIndex(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store resolving tenants by another delimiter
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithTenantIndex(store.TenantResolverDelimiter(":")),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set values of two tenants
+	for _, key := range []string{"t1:a", "t1:b", "t2:a"} {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(key, key),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// delete tenant removes the entries of its index
+	if removed, err := cacheStore.DeleteByTenant(ctx, "t1"); err != nil {
+		t.Fatal(err)
+	} else if removed != 2 {
+		t.Fatalf("expected 2 removed entries, got %d", removed)
+	}
+	if total := cacheStore.Total(ctx); total != 1 {
+		t.Fatalf("expected total 1, got %d", total)
+	}
+	if cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey("t1:a")); err != nil {
+		t.Fatal(err)
+	} else if cacheModel != nil {
+		t.Fatalf("expected t1:a to be removed")
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "t1"); count != 0 {
+		t.Fatalf("expected 0 entries, got %d", count)
+	}
+	if count, _ := cacheStore.CountByTenant(ctx, "t2"); count != 1 {
+		t.Fatalf("expected 1 entry, got %d", count)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configure TLS and mutual TLS of the Redis connection
+type TLSOptions struct {
+	// RootCAs verify the server certificate, system roots if nil
+	RootCAs *x509.CertPool
+	// Certificates are presented to the server for mutual TLS
+	Certificates []tls.Certificate
+	// ServerName overrides the host name used to verify the server certificate
+	ServerName string
+	// MinVersion is the minimum accepted TLS version, defaults to TLS 1.2
+	MinVersion uint16
+}
+
+// tlsConfig returns a copy of base (may be nil) with these options applied
+func (o *TLSOptions) tlsConfig(base *tls.Config) *tls.Config {
+	cfg := &tls.Config{}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if o.RootCAs != nil {
+		cfg.RootCAs = o.RootCAs
+	}
+	if len(o.Certificates) > 0 {
+		cfg.Certificates = o.Certificates
+	}
+	if len(o.ServerName) > 0 {
+		cfg.ServerName = o.ServerName
+	}
+	if o.MinVersion != 0 {
+		cfg.MinVersion = o.MinVersion
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	return cfg
+}
+
+func (o *TLSOptions) appendCA(caPEM []byte) error {
+	if o.RootCAs == nil {
+		o.RootCAs = x509.NewCertPool()
+	}
+	if !o.RootCAs.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("invalid CA bundle: no PEM encoded certificates found")
+	}
+	return nil
+}
+
+// withTLS enables TLS and applies fn to the TLS options
+func withTLS(fn func(o *TLSOptions) error) Option {
+	return func(o *Options) (*Options, error) {
+		if o.TLS == nil {
+			o.TLS = &TLSOptions{}
+		}
+		if err := fn(o.TLS); err != nil {
+			return nil, err
+		}
+		return o, nil
+	}
+}
+
+// OptionWithTLS enables TLS using the system roots to verify the server
+func OptionWithTLS() Option {
+	return withTLS(func(o *TLSOptions) error {
+		return nil
+	})
+}
+
+// OptionWithTLSCA verifies the server certificate against the given PEM
+// encoded CA bundle instead of the system roots
+func OptionWithTLSCA(caPEM []byte) Option {
+	return withTLS(func(o *TLSOptions) error {
+		return o.appendCA(caPEM)
+	})
+}
+
+func OptionWithTLSCAFile(caFile string) Option {
+	return withTLS(func(o *TLSOptions) error {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		return o.appendCA(caPEM)
+	})
+}
+
+// OptionWithTLSClientCert presents the given PEM encoded certificate and
+// private key to the server (mutual TLS)
+func OptionWithTLSClientCert(certPEM, keyPEM []byte) Option {
+	return withTLS(func(o *TLSOptions) error {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+		o.Certificates = append(o.Certificates, cert)
+		return nil
+	})
+}
+
+func OptionWithTLSClientCertFile(certFile, keyFile string) Option {
+	return withTLS(func(o *TLSOptions) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("invalid client certificate: %w", err)
+		}
+		o.Certificates = append(o.Certificates, cert)
+		return nil
+	})
+}
+
+func OptionWithTLSServerName(serverName string) Option {
+	return withTLS(func(o *TLSOptions) error {
+		o.ServerName = serverName
+		return nil
+	})
+}
+
+// OptionWithTLSMinVersion sets the minimum TLS version, e.g. tls.VersionTLS13
+func OptionWithTLSMinVersion(minVersion uint16) Option {
+	return withTLS(func(o *TLSOptions) error {
+		switch minVersion {
+		case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+		default:
+			return fmt.Errorf("invalid TLS version %#x", minVersion)
+		}
+		o.MinVersion = minVersion
+		return nil
+	})
+}
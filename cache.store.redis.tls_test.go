@@ -0,0 +1,218 @@
+package store_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testCerts holds PEM encoded certificates generated for a test
+type testCerts struct {
+	caPEM, serverCertPEM, serverKeyPEM, clientCertPEM, clientKeyPEM []byte
+}
+
+func generateTestCerts(t *testing.T) *testCerts {
+	newKey := func() (*ecdsa.PrivateKey, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	}
+	newCert := func(template, parent *x509.Certificate, pub, priv any) (*x509.Certificate, []byte) {
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	certs := &testCerts{}
+	notBefore, notAfter := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	caKey, _ := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "comby-test-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	ca, caPEM := newCert(caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	certs.caPEM = caPEM
+
+	serverKey, serverKeyPEM := newKey()
+	_, certs.serverCertPEM = newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "redis.test"},
+		DNSNames:     []string{"redis.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, &serverKey.PublicKey, caKey)
+	certs.serverKeyPEM = serverKeyPEM
+
+	clientKey, clientKeyPEM := newKey()
+	_, certs.clientCertPEM = newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "comby-test-client"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, &clientKey.PublicKey, caKey)
+	certs.clientKeyPEM = clientKeyPEM
+
+	return certs
+}
+
+// startTLSRedisServer starts a local redis-server accepting TLS connections
+// only, requiring client certificates, and returns its address
+func startTLSRedisServer(t *testing.T, certs *testCerts) (string, string) {
+	lookupRedisServer(t)
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"ca.crt":     certs.caPEM,
+		"server.crt": certs.serverCertPEM,
+		"server.key": certs.serverKeyPEM,
+		"client.crt": certs.clientCertPEM,
+		"client.key": certs.clientKeyPEM,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addr, ok := runRedisServer(t, "--tls-port",
+		"--port", "0",
+		"--tls-cert-file", filepath.Join(dir, "server.crt"),
+		"--tls-key-file", filepath.Join(dir, "server.key"),
+		"--tls-ca-cert-file", filepath.Join(dir, "ca.crt"),
+		"--tls-auth-clients", "yes",
+	)
+	if !ok {
+		t.Skip("redis-server did not start with TLS (built without TLS support?)")
+	}
+	return addr, dir
+}
+
+func TestCacheStoreTLS_MutualTLS(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	certs := generateTestCerts(t)
+	addr, dir := startTLSRedisServer(t, certs)
+
+	// setup and init store using PEM files
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: addr},
+		store.OptionWithTLSCAFile(filepath.Join(dir, "ca.crt")),
+		store.OptionWithTLSClientCertFile(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")),
+		store.OptionWithTLSServerName("redis.test"),
+		store.OptionWithTLSMinVersion(tls.VersionTLS12),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set and get a value
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tlsKey", "tlsValue"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("tlsKey"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "tlsValue" {
+		t.Fatalf("wrong value: %q", cacheModel.Value)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStoreTLS_MissingClientCert(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	certs := generateTestCerts(t)
+	addr, _ := startTLSRedisServer(t, certs)
+
+	// setup and init store using PEM bytes but without client certificate
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: addr, MaxRetries: -1},
+		store.OptionWithTLSCA(certs.caPEM),
+		store.OptionWithTLSServerName("redis.test"),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// server requires a client certificate
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tlsKey", "tlsValue"),
+	); err == nil {
+		t.Fatalf("expected error without client certificate")
+	}
+
+	// close connection
+	cacheStore.Close(ctx)
+}
+
+func TestCacheStoreTLS_InvalidOptions(t *testing.T) {
+	certs := generateTestCerts(t)
+
+	tests := map[string]store.Option{
+		"invalid CA":          store.OptionWithTLSCA([]byte("no pem")),
+		"missing CA file":     store.OptionWithTLSCAFile("/does/not/exist.crt"),
+		"mismatching key":     store.OptionWithTLSClientCert(certs.clientCertPEM, certs.serverKeyPEM),
+		"missing cert file":   store.OptionWithTLSClientCertFile("/does/not/exist.crt", "/does/not/exist.key"),
+		"invalid TLS version": store.OptionWithTLSMinVersion(0x0200),
+	}
+	for name, opt := range tests {
+		if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"}, opt); cacheStore != nil {
+			t.Fatalf("%s: expected nil when option fails", name)
+		}
+	}
+
+	// valid options
+	if cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithTLSCA(certs.caPEM),
+		store.OptionWithTLSClientCert(certs.clientCertPEM, certs.clientKeyPEM),
+	); cacheStore == nil {
+		t.Fatalf("expected store with valid TLS options")
+	} else if str := cacheStore.String(); str != "rediss://:***@localhost:6379/0" {
+		t.Fatalf("wrong string representation: %q", str)
+	}
+}
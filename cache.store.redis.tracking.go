@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// trackingChannel delivers invalidations of client tracking to connections
+// using RESP2
+const trackingChannel = "__redis__:invalidate"
+
+// newTrackingClient returns a client for the connection receiving
+// invalidations. Every connection enables client tracking in broadcasting
+// mode on the namespace, redirecting invalidations to itself. As go-redis
+// does not support RESP3 push messages, the connection switches to RESP2
+// which delivers invalidations as messages on trackingChannel. Reconnects
+// create a new connection, enabling client tracking again.
+func (csr *cacheStoreRedis) newTrackingClient() redis.UniversalClient {
+	tlsOptions := csr.storeOptions.TLS
+	var onConnect func(ctx context.Context, cn *redis.Conn) error
+	if csr.failoverOptions != nil {
+		onConnect = csr.failoverOptions.OnConnect
+	} else {
+		onConnect = csr.redisOptions.OnConnect
+	}
+	trackingOnConnect := func(ctx context.Context, cn *redis.Conn) error {
+		if onConnect != nil {
+			if err := onConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		if err := cn.Process(ctx, redis.NewCmd(ctx, "HELLO", 2)); err != nil {
+			return err
+		}
+		args := []any{"CLIENT", "TRACKING", "ON", "REDIRECT", id, "BCAST"}
+		if len(csr.storeOptions.Namespace) > 0 {
+			args = append(args, "PREFIX", csr.storeOptions.Namespace)
+		}
+		return cn.Process(ctx, redis.NewCmd(ctx, args...))
+	}
+
+	if csr.failoverOptions != nil {
+		failoverOptions := *csr.failoverOptions
+		if tlsOptions != nil {
+			failoverOptions.TLSConfig = tlsOptions.tlsConfig(failoverOptions.TLSConfig)
+		}
+		failoverOptions.OnConnect = trackingOnConnect
+		failoverOptions.PoolSize = 1
+		return redis.NewFailoverClient(&failoverOptions)
+	}
+	redisOptions := *csr.redisOptions
+	if tlsOptions != nil {
+		redisOptions.TLSConfig = tlsOptions.tlsConfig(redisOptions.TLSConfig)
+	}
+	redisOptions.OnConnect = trackingOnConnect
+	redisOptions.PoolSize = 1
+	return redis.NewClient(&redisOptions)
+}
+
+// applyTracking drops the keys reported by client tracking. Keys of the
+// namespace not written by this store, e.g. internal keys, are ignored.
+func (csr *cacheStoreRedis) applyTracking(msg *redis.Message) {
+	redisKeys := msg.PayloadSlice
+	if len(redisKeys) == 0 && len(msg.Payload) > 0 {
+		redisKeys = []string{msg.Payload}
+	}
+	keys := make([]string, 0, len(redisKeys))
+	for _, redisKey := range redisKeys {
+		if csr.isInternal(redisKey) || !strings.HasPrefix(redisKey, csr.storeOptions.Namespace) {
+			continue
+		}
+		keys = append(keys, csr.unkey(redisKey))
+	}
+	if len(keys) == 0 {
+		return
+	}
+	csr.nearCache.invalidations.Add(1)
+	csr.nearCache.invalidate(keys...)
+}
@@ -0,0 +1,158 @@
+package store_test
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	store "github.com/gradientzero/comby-store-redis"
+	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// startRedisServer starts a local redis-server of at least version 6, which
+// supports client tracking, and returns its address
+func startRedisServer(t *testing.T) string {
+	version, err := exec.Command(lookupRedisServer(t), "--version").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match := regexp.MustCompile(`v=(\d+)\.`).FindSubmatch(version); match == nil {
+		t.Skipf("unknown redis-server version %q", version)
+	} else if major, _ := strconv.Atoi(string(match[1])); major < 6 {
+		t.Skipf("redis-server %s does not support client tracking", match[1])
+	}
+	addr, ok := runRedisServer(t, "--port")
+	if !ok {
+		t.Fatal("redis-server did not start")
+	}
+	return addr
+}
+
+func TestCacheStore_ClientTracking(t *testing.T) {
+	var err error
+	ctx := context.Background()
+	addr := startRedisServer(t)
+
+	// setup and init store with server-assisted invalidation
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: addr},
+		store.OptionWithNamespace("app:"),
+		store.OptionWithNearCache(100, time.Minute),
+		store.OptionWithClientTracking(),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	redisClient := redis.NewClient(&redis.Options{Addr: addr})
+	defer redisClient.Close()
+
+	getValue := func(key string) any {
+		cacheModel, err := cacheStore.Get(ctx, comby.CacheStoreGetOptionWithKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cacheModel == nil {
+			return nil
+		}
+		return cacheModel.Value
+	}
+	waitForValue := func(key string, value any) {
+		deadline := time.Now().Add(2 * time.Second)
+		for getValue(key) != value {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %v for %s, got %v", value, key, getValue(key))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	setValue := func(key string, value any) {
+		if err := cacheStore.Set(ctx, comby.CacheStoreSetOptionWithKeyValue(key, value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// second read is served in process
+	setValue("key", "v1")
+	waitForValue("key", "v1")
+	before := cacheStore.Stats()
+	if value := getValue("key"); value != "v1" {
+		t.Fatalf("wrong value: %v", value)
+	}
+	if after := cacheStore.Stats(); after.Near.Hits != before.Near.Hits+1 {
+		t.Fatalf("expected near cache hit: %+v", after)
+	}
+
+	// writes by other clients are invalidated by the server
+	rawValue, err := redisClient.Get(ctx, "app:key").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setValue("other", "v2")
+	otherValue := redisClient.Get(ctx, "app:other").Val()
+	if err := redisClient.Set(ctx, "app:key", otherValue, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	waitForValue("key", "v2")
+	if cacheStore.Stats().NearInvalidations == 0 {
+		t.Fatalf("expected invalidations")
+	}
+
+	// keys outside the namespace are not tracked
+	if err := redisClient.Set(ctx, "foreign", rawValue, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// flushes drop all entries
+	if err := redisClient.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	waitForValue("key", nil)
+
+	// killing the invalidation connection flushes the near cache, tracking
+	// is enabled again on reconnect
+	setValue("key", "v3")
+	waitForValue("key", "v3")
+	if err := redisClient.ClientKillByFilter(ctx, "TYPE", "pubsub").Err(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := redisClient.Set(ctx, "app:key", otherValue, 0).Err(); err != nil {
+			t.Fatal(err)
+		}
+		if getValue("key") == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected invalidation after reconnect")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_ClientTrackingOptions(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]store.CacheStoreRedis{
+		"without near cache": store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+			store.OptionWithClientTracking(),
+		),
+		"on cluster": store.NewCacheStoreRedisCluster(&redis.ClusterOptions{Addrs: []string{"localhost:6379"}},
+			store.OptionWithNearCache(100, time.Minute),
+			store.OptionWithClientTracking(),
+		),
+	}
+	for name, cacheStore := range tests {
+		if err := cacheStore.Init(ctx); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
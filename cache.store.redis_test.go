@@ -8,6 +8,7 @@ import (
 
 	store "github.com/gradientzero/comby-store-redis"
 	"github.com/gradientzero/comby/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 func TestCacheStore1(t *testing.T) {
@@ -219,8 +220,8 @@ func TestCacheStoreWithEncryption(t *testing.T) {
 	); err != nil {
 		t.Fatal(err)
 	} else {
-		// JSON unmarshaling will convert integers to float64
-		if cacheModel.Value != float64(42) {
+		// codec preserves the type of the stored value
+		if cacheModel.Value != 42 {
 			t.Fatalf("wrong value: %v (type: %T)", cacheModel.Value, cacheModel.Value)
 		}
 	}
@@ -531,6 +532,7 @@ func TestCacheStore_ExpiredAt(t *testing.T) {
 	}
 
 	// Set key with expiration
+	before := time.Now()
 	if err := cacheStore.Set(ctx,
 		comby.CacheStoreSetOptionWithKeyValue("key-with-ttl", "value"),
 		comby.CacheStoreSetOptionWithExpiration(60*time.Second),
@@ -538,19 +540,47 @@ func TestCacheStore_ExpiredAt(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Set key without expiration
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("key-without-ttl", "value"),
+		comby.CacheStoreSetOptionWithExpiration(0),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// Get key and check ExpiredAt
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("key-with-ttl"),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		min := before.Add(59 * time.Second).UnixNano()
+		max := time.Now().Add(60 * time.Second).UnixNano()
+		if cacheModel.ExpiredAt < min || cacheModel.ExpiredAt > max {
+			t.Fatalf("ExpiredAt %d not within [%d, %d]", cacheModel.ExpiredAt, min, max)
+		}
+	}
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("key-without-ttl"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.ExpiredAt != 0 {
+		t.Fatalf("expected ExpiredAt 0 for key without expiration, got %d", cacheModel.ExpiredAt)
+	}
+
 	// List keys and check ExpiredAt
 	if cacheModels, _, err := cacheStore.List(ctx); err != nil {
 		t.Fatal(err)
 	} else {
-		if len(cacheModels) != 1 {
-			t.Fatalf("expected 1 key, got %d", len(cacheModels))
+		if len(cacheModels) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(cacheModels))
 		}
-		// NOTE: This test documents current behavior where ExpiredAt is always 0
-		// In a proper implementation, ExpiredAt should contain the TTL
-		if cacheModels[0].ExpiredAt != 0 {
-			t.Logf("ExpiredAt is %d (expected: non-zero timestamp)", cacheModels[0].ExpiredAt)
-		} else {
-			t.Logf("WARNING: ExpiredAt is 0 - TTL information is not retrieved from Redis")
+		// ordered by key: key-with-ttl, key-without-ttl
+		if cacheModels[0].ExpiredAt < before.Add(59*time.Second).UnixNano() {
+			t.Fatalf("wrong ExpiredAt %d", cacheModels[0].ExpiredAt)
+		}
+		if cacheModels[1].ExpiredAt != 0 {
+			t.Fatalf("expected ExpiredAt 0 for key without expiration, got %d", cacheModels[1].ExpiredAt)
 		}
 	}
 
@@ -690,3 +720,375 @@ func TestCacheStore_NewWithInvalidOptions(t *testing.T) {
 		t.Fatalf("expected nil when option fails, got non-nil")
 	}
 }
+
+func TestCacheStore_ListScan(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with a small scan count to force multiple batches
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379"},
+		store.OptionWithScanCount(10),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set keys for two tenants, one of them containing glob characters
+	for i := 0; i < 250; i++ {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("tenant1-key%d", i), "value"),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("ten*nt-key%d", i), "value"),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// List all keys
+	if cacheModels, total, err := cacheStore.List(ctx); err != nil {
+		t.Fatal(err)
+	} else {
+		if len(cacheModels) != 255 {
+			t.Fatalf("expected 255 keys, got %d", len(cacheModels))
+		}
+		if total != 255 {
+			t.Fatalf("expected total 255, got %d", total)
+		}
+	}
+
+	// List with tenant1 filter
+	if cacheModels, _, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("tenant1"),
+	); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 250 {
+		t.Fatalf("expected 250 keys for tenant1, got %d", len(cacheModels))
+	}
+
+	// List with glob characters in tenant filter must match literally
+	if cacheModels, _, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("ten*nt"),
+	); err != nil {
+		t.Fatal(err)
+	} else if len(cacheModels) != 5 {
+		t.Fatalf("expected 5 keys for ten*nt, got %d", len(cacheModels))
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_ListPagination(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedis("localhost:6379", "", 0)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set keys with descending expiration, so that TTL order is reversed key order
+	for i := 0; i < 25; i++ {
+		if err := cacheStore.Set(ctx,
+			comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("tenant1-key%02d", i), i),
+			comby.CacheStoreSetOptionWithExpiration(time.Duration(100-i)*time.Second),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant2-key", "value"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// First page ordered by key
+	if cacheModels, total, err := cacheStore.ListWithOptions(ctx,
+		store.ListOptionWithTenantUuid("tenant1"),
+		store.ListOptionWithPage(1, 10),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		if total != 25 {
+			t.Fatalf("expected total 25, got %d", total)
+		}
+		if len(cacheModels) != 10 {
+			t.Fatalf("expected 10 keys, got %d", len(cacheModels))
+		}
+		if cacheModels[0].Key != "tenant1-key00" {
+			t.Fatalf("wrong first key: %q", cacheModels[0].Key)
+		}
+	}
+
+	// Last page ordered by key
+	if cacheModels, total, err := cacheStore.ListWithOptions(ctx,
+		store.ListOptionWithTenantUuid("tenant1"),
+		store.ListOptionWithPage(3, 10),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		if total != 25 {
+			t.Fatalf("expected total 25, got %d", total)
+		}
+		if len(cacheModels) != 5 {
+			t.Fatalf("expected 5 keys, got %d", len(cacheModels))
+		}
+		if cacheModels[4].Key != "tenant1-key24" {
+			t.Fatalf("wrong last key: %q", cacheModels[4].Key)
+		}
+	}
+
+	// Offset and limit ordered by remaining TTL
+	if cacheModels, total, err := cacheStore.ListWithOptions(ctx,
+		store.ListOptionWithTenantUuid("tenant1"),
+		store.ListOptionWithOrderBy(store.ListOrderByTTL, true),
+		store.ListOptionWithOffset(1),
+		store.ListOptionWithLimit(2),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		if total != 25 {
+			t.Fatalf("expected total 25, got %d", total)
+		}
+		if len(cacheModels) != 2 {
+			t.Fatalf("expected 2 keys, got %d", len(cacheModels))
+		}
+		if cacheModels[0].Key != "tenant1-key23" || cacheModels[1].Key != "tenant1-key22" {
+			t.Fatalf("wrong keys: %q, %q", cacheModels[0].Key, cacheModels[1].Key)
+		}
+	}
+
+	// Offset beyond total
+	if cacheModels, total, err := cacheStore.ListWithOptions(ctx,
+		store.ListOptionWithOffset(100),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		if total != 26 {
+			t.Fatalf("expected total 26, got %d", total)
+		}
+		if len(cacheModels) != 0 {
+			t.Fatalf("expected 0 keys, got %d", len(cacheModels))
+		}
+	}
+
+	// Invalid order
+	if _, _, err := cacheStore.ListWithOptions(ctx,
+		store.ListOptionWithOrderBy("value", true),
+	); err == nil {
+		t.Fatalf("expected error for invalid order")
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_Namespace(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// foreign keys not owned by the store
+	redisClient := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 3})
+	defer redisClient.Close()
+	if err := redisClient.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := redisClient.Set(ctx, "session-1", "foreign", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// setup and init store
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithNamespace("cache:"),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset namespace must keep foreign keys
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheStore.Total(ctx) != 0 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// Set values
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-key1", "value1"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheStore.Set(ctx,
+		comby.CacheStoreSetOptionWithKeyValue("tenant1-key2", "value2"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// keys are prefixed in redis
+	if n := redisClient.Exists(ctx, "cache:tenant1-key1").Val(); n != 1 {
+		t.Fatalf("expected prefixed key in redis")
+	}
+
+	// Get a value
+	if cacheModel, err := cacheStore.Get(ctx,
+		comby.CacheStoreGetOptionWithKey("tenant1-key1"),
+	); err != nil {
+		t.Fatal(err)
+	} else if cacheModel.Value != "value1" {
+		t.Fatalf("wrong value: %q", cacheModel.Value)
+	}
+
+	// List strips the namespace
+	if cacheModels, total, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("tenant1"),
+	); err != nil {
+		t.Fatal(err)
+	} else {
+		if total != 2 {
+			t.Fatalf("expected total 2, got %d", total)
+		}
+		if cacheModels[0].Key != "tenant1-key1" {
+			t.Fatalf("wrong key: %q", cacheModels[0].Key)
+		}
+	}
+
+	// Total and Info are scoped to the namespace
+	if cacheStore.Total(ctx) != 2 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+	if info, err := cacheStore.Info(ctx); err != nil {
+		t.Fatal(err)
+	} else if info.NumItems != 2 {
+		t.Fatalf("expected 2 items, got %d", info.NumItems)
+	}
+
+	// Delete a key
+	if err := cacheStore.Delete(ctx,
+		comby.CacheStoreDeleteOptionWithKey("tenant1-key2"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if cacheStore.Total(ctx) != 1 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// reset namespace
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cacheStore.Total(ctx) != 0 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+	if v := redisClient.Get(ctx, "session-1").Val(); v != "foreign" {
+		t.Fatalf("foreign key was removed")
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
+
+func TestCacheStore_DeleteByTenant(t *testing.T) {
+	var err error
+	ctx := context.Background()
+
+	// setup and init store with small scan batches
+	cacheStore := store.NewCacheStoreRedisWithOptions(&redis.Options{Addr: "localhost:6379", DB: 3},
+		store.OptionWithNamespace("cache:"),
+		store.OptionWithScanCount(10),
+	)
+	if err = cacheStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// reset database
+	if err := cacheStore.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set values of tenants sharing a prefix
+	for i := 0; i < 25; i++ {
+		for _, tenantUuid := range []string{"tenant1", "tenant10"} {
+			if err := cacheStore.Set(ctx,
+				comby.CacheStoreSetOptionWithKeyValue(fmt.Sprintf("%s-key%d", tenantUuid, i), i),
+			); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// tenants sharing a prefix are listed separately
+	if _, total, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("tenant1"),
+	); err != nil {
+		t.Fatal(err)
+	} else if total != 25 {
+		t.Fatalf("expected total 25, got %d", total)
+	}
+
+	// delete one tenant only
+	if removed, err := cacheStore.DeleteByTenant(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if removed != 25 {
+		t.Fatalf("expected 25 removed keys, got %d", removed)
+	}
+	if _, total, err := cacheStore.List(ctx,
+		comby.CacheStoreListOptionWithTenantUuid("tenant10"),
+	); err != nil {
+		t.Fatal(err)
+	} else if total != 25 {
+		t.Fatalf("expected total 25, got %d", total)
+	}
+	if cacheStore.Total(ctx) != 25 {
+		t.Fatalf("wrong total %d", cacheStore.Total(ctx))
+	}
+
+	// deleting again removes nothing
+	if removed, err := cacheStore.DeleteByTenant(ctx, "tenant1"); err != nil {
+		t.Fatal(err)
+	} else if removed != 0 {
+		t.Fatalf("expected 0 removed keys, got %d", removed)
+	}
+
+	// empty tenant is rejected
+	if _, err := cacheStore.DeleteByTenant(ctx, ""); err == nil {
+		t.Fatalf("expected error with empty tenant")
+	}
+
+	// close connection
+	if err := cacheStore.Close(ctx); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+}
@@ -6,7 +6,9 @@ replace github.com/gradientzero/comby/v2 v2.4.0 => /Users/me/Documents/gradient0
 
 require (
 	github.com/gradientzero/comby/v2 v2.4.0
+	github.com/klauspost/compress v1.17.9
 	github.com/redis/go-redis/v9 v9.0.0
+	golang.org/x/sync v0.8.0
 )
 
 require (